@@ -260,6 +260,25 @@ func IndexExpr(x *ast.IndexExpr, nMap CopyNodeMap) *ast.IndexExpr {
 	return &cp
 }
 
+// IndexListExpr returns x deep copy.
+// Copy of nil argument is nil.
+func IndexListExpr(x *ast.IndexListExpr, nMap CopyNodeMap) *ast.IndexListExpr {
+	if x == nil {
+		return nil
+	}
+	cp := *x
+	cp.X = copyExpr(x.X, nMap)
+	cp.Indices = ExprList(x.Indices, nMap)
+	if nMap != nil {
+		base := nMap[x]
+		if base == nil {
+			base = x
+		}
+		nMap[&cp] = base
+	}
+	return &cp
+}
+
 // SliceExpr returns x deep copy.
 // Copy of nil argument is nil.
 func SliceExpr(x *ast.SliceExpr, nMap CopyNodeMap) *ast.SliceExpr {
@@ -482,6 +501,7 @@ func FuncType(x *ast.FuncType, nMap CopyNodeMap) *ast.FuncType {
 		return nil
 	}
 	cp := *x
+	cp.TypeParams = FieldList(x.TypeParams, nMap)
 	cp.Params = FieldList(x.Params, nMap)
 	cp.Results = FieldList(x.Results, nMap)
 	if nMap != nil {
@@ -618,6 +638,7 @@ func TypeSpec(x *ast.TypeSpec, nMap CopyNodeMap) *ast.TypeSpec {
 	}
 	cp := *x
 	cp.Name = Ident(x.Name, nMap)
+	cp.TypeParams = FieldList(x.TypeParams, nMap)
 	cp.Type = copyExpr(x.Type, nMap)
 	cp.Doc = CommentGroup(x.Doc, nMap)
 	cp.Comment = CommentGroup(x.Comment, nMap)
@@ -1089,14 +1110,18 @@ func File(x *ast.File, nMap CopyNodeMap) *ast.File {
 	cp.Doc = CommentGroup(x.Doc, nMap)
 	cp.Name = Ident(x.Name, nMap)
 	cp.Decls = DeclList(x.Decls, nMap)
-	cp.Imports = make([]*ast.ImportSpec, len(x.Imports))
-	for i := range x.Imports {
-		cp.Imports[i] = ImportSpec(x.Imports[i], nMap)
+	if x.Imports != nil {
+		cp.Imports = make([]*ast.ImportSpec, len(x.Imports))
+		for i := range x.Imports {
+			cp.Imports[i] = ImportSpec(x.Imports[i], nMap)
+		}
 	}
 	cp.Unresolved = IdentList(x.Unresolved, nMap)
-	cp.Comments = make([]*ast.CommentGroup, len(x.Comments))
-	for i := range x.Comments {
-		cp.Comments[i] = CommentGroup(x.Comments[i], nMap)
+	if x.Comments != nil {
+		cp.Comments = make([]*ast.CommentGroup, len(x.Comments))
+		for i := range x.Comments {
+			cp.Comments[i] = CommentGroup(x.Comments[i], nMap)
+		}
 	}
 	if nMap != nil {
 		base := nMap[x]
@@ -1238,6 +1263,8 @@ func copyExpr(x ast.Expr, nMap CopyNodeMap) ast.Expr {
 		return SelectorExpr(x, nMap)
 	case *ast.IndexExpr:
 		return IndexExpr(x, nMap)
+	case *ast.IndexListExpr:
+		return IndexListExpr(x, nMap)
 	case *ast.SliceExpr:
 		return SliceExpr(x, nMap)
 	case *ast.TypeAssertExpr:
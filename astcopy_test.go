@@ -0,0 +1,322 @@
+package astcopy_test
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/vvakame/astcopy"
+)
+
+func TestFileGoroot(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping GOROOT walk in short mode")
+	}
+
+	root := filepath.Join(runtime.GOROOT(), "src")
+	var files int
+	err := filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(name, ".go") {
+			return err
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			// Some testdata files are broken on purpose.
+			return nil
+		}
+		want, ok := printFile(fset, f)
+		if !ok {
+			return nil
+		}
+		files++
+
+		rel, _ := filepath.Rel(root, name)
+		cp := astcopy.File(f, nil)
+		have, ok := printFile(fset, cp)
+		if !ok || !bytes.Equal(have, want) {
+			t.Errorf("%s: printed copy differs", rel)
+		}
+		if path := shapeDiff(reflect.ValueOf(f), reflect.ValueOf(cp), "File"); path != "" {
+			t.Errorf("%s: copy shape differs at %s", rel, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files == 0 {
+		t.Fatal("no files checked")
+	}
+}
+
+// shapeDiff returns the path of the first x and y difference in
+// nil-ness of pointers, interfaces and slices or in slice lengths.
+// Objects and scopes are not compared.
+func shapeDiff(x, y reflect.Value, path string) string {
+	switch x.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if x.IsNil() != y.IsNil() {
+			return path
+		}
+		if x.IsNil() {
+			return ""
+		}
+		switch x.Interface().(type) {
+		case *ast.Object, *ast.Scope:
+			return ""
+		}
+		return shapeDiff(x.Elem(), y.Elem(), path)
+	case reflect.Slice:
+		if x.IsNil() != y.IsNil() || x.Len() != y.Len() {
+			return path
+		}
+		for i := 0; i < x.Len(); i++ {
+			if p := shapeDiff(x.Index(i), y.Index(i), path+"["+strconv.Itoa(i)+"]"); p != "" {
+				return p
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < x.NumField(); i++ {
+			if p := shapeDiff(x.Field(i), y.Field(i), path+"."+x.Type().Field(i).Name); p != "" {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+func FuzzFile(f *testing.F) {
+	files, err := filepath.Glob(filepath.Join(runtime.GOROOT(), "src", "go", "*", "*.go"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, name := range files {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(src)
+	}
+
+	f.Fuzz(func(t *testing.T, src []byte) {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "fuzz.go", src, parser.ParseComments)
+		if err != nil {
+			t.Skip()
+		}
+		want, ok := printFile(fset, file)
+		if !ok {
+			t.Skip()
+		}
+		origs := fileNodes(file)
+
+		nMap := astcopy.CopyNodeMap{}
+		checkFileCopy(t, fset, want, origs, astcopy.File(file, nMap), nMap)
+
+		c := astcopy.Copier{NodeMap: astcopy.CopyNodeMap{}}
+		checkFileCopy(t, fset, want, origs, c.Copy(file).(*ast.File), c.NodeMap)
+	})
+}
+
+// checkFileCopy checks that cp prints as want, doesn't share nodes
+// with origs and that all cp nodes are mapped to origs in nMap.
+func checkFileCopy(t *testing.T, fset *token.FileSet, want []byte, origs map[ast.Node]bool, cp *ast.File, nMap astcopy.CopyNodeMap) {
+	t.Helper()
+	have, ok := printFile(fset, cp)
+	if !ok {
+		t.Fatal("copy print failed")
+	}
+	if !bytes.Equal(have, want) {
+		t.Fatalf("printed copy differs:\n%s\nwant:\n%s", have, want)
+	}
+	for n := range fileNodes(cp) {
+		if origs[n] {
+			t.Fatalf("%T node is not copied", n)
+		}
+		if !origs[nMap[n]] {
+			t.Fatalf("%T node is not mapped to its original", n)
+		}
+	}
+}
+
+// fileNodes returns all x nodes, including the nodes
+// that ast.Inspect doesn't visit.
+func fileNodes(x *ast.File) map[ast.Node]bool {
+	nodes := make(map[ast.Node]bool)
+	visit := func(n ast.Node) bool {
+		if n != nil {
+			nodes[n] = true
+		}
+		return true
+	}
+	ast.Inspect(x, visit)
+	for _, spec := range x.Imports {
+		ast.Inspect(spec, visit)
+	}
+	for _, id := range x.Unresolved {
+		visit(id)
+	}
+	for _, cg := range x.Comments {
+		ast.Inspect(cg, visit)
+	}
+	return nodes
+}
+
+// printFile returns x printed like gofmt does.
+// It reports false if x can't be printed.
+func printFile(fset *token.FileSet, x *ast.File) (out []byte, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	var buf bytes.Buffer
+	cfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	if err := cfg.Fprint(&buf, fset, x); err != nil {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+func BenchmarkCopy(b *testing.B) {
+	b.Run("Expr", func(b *testing.B) {
+		x, err := parser.ParseExpr(`a[i] + f(x, y...) * -z.w`)
+		if err != nil {
+			b.Fatal(err)
+		}
+		benchmarkCopy(b, []ast.Node{x})
+	})
+	b.Run("LargeFile", func(b *testing.B) {
+		var src strings.Builder
+		src.WriteString("package p\n\nimport \"fmt\"\n")
+		for i := 0; i < 1000; i++ {
+			fmt.Fprintf(&src, `
+// F%[1]d is a generated function.
+func F%[1]d(xs []int, m map[string]int) (sum int) {
+	for i, x := range xs {
+		if x%%2 == 0 {
+			sum += x * i
+		} else {
+			m[fmt.Sprint(x)]++
+		}
+	}
+	switch {
+	case sum > %[1]d:
+		return sum - %[1]d
+	}
+	return sum
+}
+`, i)
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), "large.go", src.String(), parser.ParseComments)
+		if err != nil {
+			b.Fatal(err)
+		}
+		benchmarkCopy(b, []ast.Node{f})
+	})
+	b.Run("Stdlib", func(b *testing.B) {
+		pkgs, err := parser.ParseDir(token.NewFileSet(), filepath.Join(runtime.GOROOT(), "src", "go", "types"), nil, parser.ParseComments)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var files []ast.Node
+		for _, f := range pkgs["types"].Files {
+			files = append(files, f)
+		}
+		benchmarkCopy(b, files)
+	})
+}
+
+// benchmarkCopy runs copy benchmarks of xs, reporting
+// allocations and the time per copied node.
+func benchmarkCopy(b *testing.B, xs []ast.Node) {
+	var nodes int
+	for _, x := range xs {
+		ast.Inspect(x, func(n ast.Node) bool {
+			if n != nil {
+				nodes++
+			}
+			return true
+		})
+	}
+	run := func(name string, copyNode func(x ast.Node)) {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for _, x := range xs {
+					copyNode(x)
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*nodes), "ns/node")
+		})
+	}
+
+	run("Node", func(x ast.Node) {
+		astcopy.Node(x, nil)
+	})
+	run("NodeMap", func(x ast.Node) {
+		astcopy.Node(x, astcopy.CopyNodeMap{})
+	})
+	var c astcopy.Copier
+	run("Copier", func(x ast.Node) {
+		c.Copy(x)
+	})
+	run("Reflect", func(x ast.Node) {
+		reflectCopy(reflect.ValueOf(x))
+	})
+}
+
+// reflectCopy returns v deep copy made with reflection,
+// it is the baseline of the copy benchmarks.
+// Objects and scopes are shared.
+func reflectCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		switch v.Interface().(type) {
+		case *ast.Object, *ast.Scope:
+			return v
+		}
+		cp := reflect.New(v.Type().Elem())
+		cp.Elem().Set(reflectCopy(v.Elem()))
+		return cp
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(reflectCopy(v.Elem()))
+		return cp
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(reflectCopy(v.Index(i)))
+		}
+		return cp
+	case reflect.Struct:
+		cp := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			cp.Field(i).Set(reflectCopy(v.Field(i)))
+		}
+		return cp
+	default:
+		return v
+	}
+}
@@ -0,0 +1,417 @@
+package astcopy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+)
+
+// binaryMagic starts every binary encoding.
+const binaryMagic = "GOAST"
+
+// binaryVersion is the binary encoding version.
+// It must be incremented on every encoding change.
+const binaryVersion = 1
+
+// Binary node tags, besides node kinds.
+const (
+	binaryNil byte = 0
+	binaryRef byte = 255
+)
+
+// binaryKinds lists node type names by their binary tags.
+// The order is a part of the encoding, new kinds go to the end.
+var binaryKinds = [...]string{
+	1:  "BadExpr",
+	2:  "Ident",
+	3:  "Ellipsis",
+	4:  "BasicLit",
+	5:  "FuncLit",
+	6:  "CompositeLit",
+	7:  "ParenExpr",
+	8:  "SelectorExpr",
+	9:  "IndexExpr",
+	10: "IndexListExpr",
+	11: "SliceExpr",
+	12: "TypeAssertExpr",
+	13: "CallExpr",
+	14: "StarExpr",
+	15: "UnaryExpr",
+	16: "BinaryExpr",
+	17: "KeyValueExpr",
+	18: "ArrayType",
+	19: "StructType",
+	20: "Field",
+	21: "FieldList",
+	22: "FuncType",
+	23: "InterfaceType",
+	24: "MapType",
+	25: "ChanType",
+	26: "BadStmt",
+	27: "DeclStmt",
+	28: "EmptyStmt",
+	29: "LabeledStmt",
+	30: "ExprStmt",
+	31: "SendStmt",
+	32: "IncDecStmt",
+	33: "AssignStmt",
+	34: "GoStmt",
+	35: "DeferStmt",
+	36: "ReturnStmt",
+	37: "BranchStmt",
+	38: "BlockStmt",
+	39: "IfStmt",
+	40: "CaseClause",
+	41: "SwitchStmt",
+	42: "TypeSwitchStmt",
+	43: "CommClause",
+	44: "SelectStmt",
+	45: "ForStmt",
+	46: "RangeStmt",
+	47: "ImportSpec",
+	48: "ValueSpec",
+	49: "TypeSpec",
+	50: "BadDecl",
+	51: "GenDecl",
+	52: "FuncDecl",
+	53: "Comment",
+	54: "CommentGroup",
+	55: "File",
+	56: "Package",
+}
+
+var binaryTags = func() map[string]byte {
+	tags := make(map[string]byte, len(binaryKinds))
+	for tag, name := range binaryKinds {
+		if name != "" {
+			tags[name] = byte(tag)
+		}
+	}
+	return tags
+}()
+
+// EncodeBinary writes x compact binary encoding to w.
+//
+// The encoding is versioned and can be decoded with DecodeBinary.
+// Identifier and other strings are interned, positions are stored as
+// varint deltas relative to the parent node position.
+// Nodes referenced more than once, like File.Imports elements,
+// are encoded once and stay shared after decoding.
+// Ident.Obj links and scopes are not encoded.
+func EncodeBinary(w io.Writer, x ast.Node) error {
+	e := binaryEncoder{
+		w:       bufio.NewWriter(w),
+		nodes:   make(map[ast.Node]int),
+		strings: make(map[string]int),
+	}
+	e.w.WriteString(binaryMagic)
+	e.uint(binaryVersion)
+	e.node(orNil(x), token.NoPos)
+	return e.w.Flush()
+}
+
+type binaryEncoder struct {
+	w   *bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+
+	// nodes maps encoded nodes to their indexes in the encoding order.
+	nodes map[ast.Node]int
+	// strings maps interned strings to their indexes.
+	strings map[string]int
+}
+
+func (e *binaryEncoder) uint(x uint64) {
+	n := binary.PutUvarint(e.buf[:], x)
+	e.w.Write(e.buf[:n])
+}
+
+// string writes 0 followed by s for new strings
+// and the string index plus 1 for interned ones.
+func (e *binaryEncoder) string(s string) {
+	if i, ok := e.strings[s]; ok {
+		e.uint(uint64(i) + 1)
+		return
+	}
+	e.strings[s] = len(e.strings)
+	e.uint(0)
+	e.uint(uint64(len(s)))
+	e.w.WriteString(s)
+}
+
+// pos writes 0 for invalid positions and the zigzag delta
+// from base plus 1 otherwise.
+func (e *binaryEncoder) pos(x, base token.Pos) {
+	if !x.IsValid() {
+		e.uint(0)
+		return
+	}
+	delta := int64(x) - int64(base)
+	e.uint(uint64(delta<<1^delta>>63) + 1)
+}
+
+func (e *binaryEncoder) node(x ast.Node, base token.Pos) {
+	if x == nil {
+		e.w.WriteByte(binaryNil)
+		return
+	}
+	if i, ok := e.nodes[x]; ok {
+		e.w.WriteByte(binaryRef)
+		e.uint(uint64(i))
+		return
+	}
+	e.nodes[x] = len(e.nodes)
+
+	e.w.WriteByte(binaryTags[nodeName(x)])
+	fields := nodeFields(x)
+	own := firstPos(fields, base)
+	e.pos(own, base)
+
+	for _, f := range fields {
+		switch ptr := f.ptr.(type) {
+		case *token.Pos:
+			e.pos(*ptr, own)
+		case *token.Token:
+			e.uint(uint64(*ptr))
+		case *string:
+			e.string(*ptr)
+		case *bool:
+			if *ptr {
+				e.w.WriteByte(1)
+			} else {
+				e.w.WriteByte(0)
+			}
+		case *ast.ChanDir:
+			e.uint(uint64(*ptr))
+		case **ast.Object:
+			// Objects are not encoded.
+		default:
+			if isNodeField(ptr) {
+				e.node(getNode(ptr), own)
+				continue
+			}
+			list := getList(ptr)
+			if list == nil {
+				e.uint(0)
+				continue
+			}
+			e.uint(uint64(len(list)) + 1)
+			for _, x := range list {
+				e.node(x, own)
+			}
+		}
+	}
+
+	if x, ok := x.(*ast.Package); ok {
+		names := sortedFileNames(x)
+		e.uint(uint64(len(names)))
+		for _, name := range names {
+			e.string(name)
+			e.node(orNil(x.Files[name]), own)
+		}
+	}
+}
+
+// firstPos returns the first valid position field value or base.
+func firstPos(fields []field, base token.Pos) token.Pos {
+	for _, f := range fields {
+		if pos, ok := f.ptr.(*token.Pos); ok && pos.IsValid() {
+			return *pos
+		}
+	}
+	return base
+}
+
+// DecodeBinary reads a node encoded by EncodeBinary from r.
+// For files, the result is *ast.File.
+//
+// DecodeBinary reads r through a buffer, so it may read past
+// the end of the encoded node.
+func DecodeBinary(r io.Reader) (ast.Node, error) {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	d := binaryDecoder{r: br}
+
+	magic := make([]byte, len(binaryMagic))
+	for i := range magic {
+		magic[i] = d.byte()
+	}
+	if d.err == nil && string(magic) != binaryMagic {
+		return nil, errors.New("astcopy: not a binary AST encoding")
+	}
+	if v := d.uint(); d.err == nil && v != binaryVersion {
+		return nil, fmt.Errorf("astcopy: unsupported binary AST encoding version %d", v)
+	}
+	x := d.node(token.NoPos)
+	if d.err != nil {
+		if d.err == io.EOF {
+			d.err = io.ErrUnexpectedEOF
+		}
+		return nil, d.err
+	}
+	return x, nil
+}
+
+type binaryDecoder struct {
+	r   io.ByteReader
+	err error
+
+	nodes   []ast.Node
+	strings []string
+}
+
+func (d *binaryDecoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *binaryDecoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	b, err := d.r.ReadByte()
+	d.fail(err)
+	return b
+}
+
+func (d *binaryDecoder) uint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	x, err := binary.ReadUvarint(d.r)
+	d.fail(err)
+	return x
+}
+
+func (d *binaryDecoder) string() string {
+	i := d.uint()
+	if d.err != nil {
+		return ""
+	}
+	if i != 0 {
+		if i > uint64(len(d.strings)) {
+			d.fail(errors.New("astcopy: bad string reference"))
+			return ""
+		}
+		return d.strings[i-1]
+	}
+
+	n := d.uint()
+	// The length is not trusted to preallocate the whole string.
+	var b []byte
+	for ; n > 0 && d.err == nil; n-- {
+		b = append(b, d.byte())
+	}
+	s := string(b)
+	d.strings = append(d.strings, s)
+	return s
+}
+
+func (d *binaryDecoder) pos(base token.Pos) token.Pos {
+	x := d.uint()
+	if x == 0 {
+		return token.NoPos
+	}
+	x--
+	delta := int64(x>>1) ^ -int64(x&1)
+	return token.Pos(int64(base) + delta)
+}
+
+func (d *binaryDecoder) node(base token.Pos) ast.Node {
+	tag := d.byte()
+	if d.err != nil {
+		return nil
+	}
+	switch tag {
+	case binaryNil:
+		return nil
+	case binaryRef:
+		i := d.uint()
+		if d.err == nil && i >= uint64(len(d.nodes)) {
+			d.fail(errors.New("astcopy: bad node reference"))
+		}
+		if d.err != nil {
+			return nil
+		}
+		return d.nodes[i]
+	}
+
+	var x ast.Node
+	if int(tag) < len(binaryKinds) {
+		x = newNode(binaryKinds[tag])
+	}
+	if x == nil {
+		d.fail(fmt.Errorf("astcopy: bad node tag %d", tag))
+		return nil
+	}
+	d.nodes = append(d.nodes, x)
+	own := d.pos(base)
+
+	for _, f := range nodeFields(x) {
+		if d.err != nil {
+			return nil
+		}
+		switch ptr := f.ptr.(type) {
+		case *token.Pos:
+			*ptr = d.pos(own)
+		case *token.Token:
+			*ptr = token.Token(d.uint())
+		case *string:
+			*ptr = d.string()
+		case *bool:
+			*ptr = d.byte() != 0
+		case *ast.ChanDir:
+			*ptr = ast.ChanDir(d.uint())
+		case **ast.Object:
+			// Objects are not encoded.
+		default:
+			if isNodeField(ptr) {
+				child := d.node(own)
+				if d.err == nil && !fits(ptr, child) {
+					d.fail(fmt.Errorf("astcopy: unexpected %s node in %s.%s",
+						nodeName(child), nodeName(x), f.name))
+				}
+				if d.err == nil {
+					setNode(ptr, child)
+				}
+				continue
+			}
+			n := d.uint()
+			if n == 0 {
+				continue
+			}
+			list := []ast.Node{}
+			for n--; n > 0 && d.err == nil; n-- {
+				child := d.node(own)
+				if d.err == nil && !fits(ptr, child) {
+					d.fail(fmt.Errorf("astcopy: unexpected %s node in %s.%s",
+						nodeName(child), nodeName(x), f.name))
+				}
+				list = append(list, child)
+			}
+			if d.err == nil {
+				setList(ptr, list)
+			}
+		}
+	}
+
+	if x, ok := x.(*ast.Package); ok {
+		n := d.uint()
+		x.Files = make(map[string]*ast.File)
+		for ; n > 0 && d.err == nil; n-- {
+			name := d.string()
+			f, ok := d.node(own).(*ast.File)
+			if d.err == nil && !ok {
+				d.fail(errors.New("astcopy: unexpected node in Package.Files"))
+			}
+			x.Files[name] = f
+		}
+	}
+	return x
+}
@@ -0,0 +1,110 @@
+package astcopy_test
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/vvakame/astcopy"
+)
+
+var binarySeeds = []string{
+	`package p`,
+	`package p; import "fmt"; func main() { fmt.Println("hello") }`,
+	`package p
+
+// T is a type.
+type T[K comparable, V any] struct {
+	m map[K]V // values
+	c chan<- V
+}
+
+func (t *T[K, V]) Get(k K) (v V, ok bool) {
+	v, ok = t.m[k]
+	return
+}
+`,
+	`package p
+
+func f(xs []int, ch chan int) {
+	for i := range xs[1:] {
+		select {
+		case v := <-ch:
+			_ = v + i
+		default:
+			goto end
+		}
+	}
+end:
+	defer func() { recover() }()
+	switch x := interface{}(xs).(type) {
+	case []int:
+		x = append(x, 1, 2)
+	}
+}
+`,
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	for _, src := range binarySeeds {
+		checkBinaryRoundTrip(t, src)
+	}
+}
+
+func FuzzBinaryRoundTrip(f *testing.F) {
+	for _, src := range binarySeeds {
+		f.Add(src)
+	}
+	f.Fuzz(checkBinaryRoundTrip)
+}
+
+func checkBinaryRoundTrip(t *testing.T, src string) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments)
+	if err != nil {
+		t.Skip()
+	}
+
+	var buf bytes.Buffer
+	if err := astcopy.EncodeBinary(&buf, file); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	x, err := astcopy.DecodeBinary(&buf)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	decoded, ok := x.(*ast.File)
+	if !ok {
+		t.Fatalf("decoded %T, want *ast.File", x)
+	}
+	if !astcopy.Equal(file, decoded, astcopy.IgnoreObjects) {
+		t.Fatalf("decoded file is not equal to the original:\n%s", src)
+	}
+	for i, spec := range decoded.Imports {
+		found := false
+		ast.Inspect(decoded, func(n ast.Node) bool {
+			found = found || n == spec
+			return !found
+		})
+		if !found {
+			t.Fatalf("import %d is not shared with declarations", i)
+		}
+	}
+}
+
+func TestDecodeBinaryErrors(t *testing.T) {
+	inputs := [][]byte{
+		nil,
+		[]byte("GOAS"),
+		[]byte("GOAST\x02"),
+		[]byte("GOAST\x01\x02"),
+		[]byte("GOAST\x01\xf0"),
+	}
+	for _, input := range inputs {
+		if _, err := astcopy.DecodeBinary(bytes.NewReader(input)); err == nil {
+			t.Errorf("DecodeBinary(%q): no error", input)
+		}
+	}
+}
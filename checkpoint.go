@@ -0,0 +1,285 @@
+package astcopy
+
+import (
+	"go/ast"
+)
+
+// TreeState is a saved state of a tree, see Checkpoint.
+type TreeState struct {
+	restores []func()
+}
+
+// Checkpoint saves fields of every node reachable from root.
+//
+// Restore puts the saved field values back into the same nodes,
+// so nodes stay valid keys for external maps like types.Info.
+// Slices and Package.Files maps are restored in place too.
+// Ident.Obj and scope pointers are restored, but objects and scopes
+// contents are not saved.
+func Checkpoint(root ast.Node) *TreeState {
+	s := &TreeState{}
+	seen := make(map[ast.Node]bool)
+	var visit func(x ast.Node)
+	visit = func(x ast.Node) {
+		if x == nil || seen[x] {
+			return
+		}
+		seen[x] = true
+		s.restores = append(s.restores, saveNode(x))
+		for _, f := range nodeFields(x) {
+			switch {
+			case isNodeField(f.ptr):
+				visit(getNode(f.ptr))
+			case isListField(f.ptr):
+				s.restores = append(s.restores, saveList(f.ptr))
+				for _, child := range getList(f.ptr) {
+					visit(child)
+				}
+			}
+		}
+		if x, ok := x.(*ast.Package); ok {
+			for _, f := range x.Files {
+				visit(orNil(f))
+			}
+		}
+	}
+	visit(orNil(root))
+	return s
+}
+
+// Restore puts the saved state back into the tree nodes.
+// It can be called more than once.
+func (s *TreeState) Restore() {
+	for _, restore := range s.restores {
+		restore()
+	}
+}
+
+// saveNode returns a function that restores x fields.
+func saveNode(x ast.Node) func() {
+	switch x := x.(type) {
+	case *ast.BadExpr:
+		v := *x
+		return func() { *x = v }
+	case *ast.Ident:
+		v := *x
+		return func() { *x = v }
+	case *ast.Ellipsis:
+		v := *x
+		return func() { *x = v }
+	case *ast.BasicLit:
+		v := *x
+		return func() { *x = v }
+	case *ast.FuncLit:
+		v := *x
+		return func() { *x = v }
+	case *ast.CompositeLit:
+		v := *x
+		return func() { *x = v }
+	case *ast.ParenExpr:
+		v := *x
+		return func() { *x = v }
+	case *ast.SelectorExpr:
+		v := *x
+		return func() { *x = v }
+	case *ast.IndexExpr:
+		v := *x
+		return func() { *x = v }
+	case *ast.IndexListExpr:
+		v := *x
+		return func() { *x = v }
+	case *ast.SliceExpr:
+		v := *x
+		return func() { *x = v }
+	case *ast.TypeAssertExpr:
+		v := *x
+		return func() { *x = v }
+	case *ast.CallExpr:
+		v := *x
+		return func() { *x = v }
+	case *ast.StarExpr:
+		v := *x
+		return func() { *x = v }
+	case *ast.UnaryExpr:
+		v := *x
+		return func() { *x = v }
+	case *ast.BinaryExpr:
+		v := *x
+		return func() { *x = v }
+	case *ast.KeyValueExpr:
+		v := *x
+		return func() { *x = v }
+	case *ast.ArrayType:
+		v := *x
+		return func() { *x = v }
+	case *ast.StructType:
+		v := *x
+		return func() { *x = v }
+	case *ast.Field:
+		v := *x
+		return func() { *x = v }
+	case *ast.FieldList:
+		v := *x
+		return func() { *x = v }
+	case *ast.FuncType:
+		v := *x
+		return func() { *x = v }
+	case *ast.InterfaceType:
+		v := *x
+		return func() { *x = v }
+	case *ast.MapType:
+		v := *x
+		return func() { *x = v }
+	case *ast.ChanType:
+		v := *x
+		return func() { *x = v }
+	case *ast.BadStmt:
+		v := *x
+		return func() { *x = v }
+	case *ast.DeclStmt:
+		v := *x
+		return func() { *x = v }
+	case *ast.EmptyStmt:
+		v := *x
+		return func() { *x = v }
+	case *ast.LabeledStmt:
+		v := *x
+		return func() { *x = v }
+	case *ast.ExprStmt:
+		v := *x
+		return func() { *x = v }
+	case *ast.SendStmt:
+		v := *x
+		return func() { *x = v }
+	case *ast.IncDecStmt:
+		v := *x
+		return func() { *x = v }
+	case *ast.AssignStmt:
+		v := *x
+		return func() { *x = v }
+	case *ast.GoStmt:
+		v := *x
+		return func() { *x = v }
+	case *ast.DeferStmt:
+		v := *x
+		return func() { *x = v }
+	case *ast.ReturnStmt:
+		v := *x
+		return func() { *x = v }
+	case *ast.BranchStmt:
+		v := *x
+		return func() { *x = v }
+	case *ast.BlockStmt:
+		v := *x
+		return func() { *x = v }
+	case *ast.IfStmt:
+		v := *x
+		return func() { *x = v }
+	case *ast.CaseClause:
+		v := *x
+		return func() { *x = v }
+	case *ast.SwitchStmt:
+		v := *x
+		return func() { *x = v }
+	case *ast.TypeSwitchStmt:
+		v := *x
+		return func() { *x = v }
+	case *ast.CommClause:
+		v := *x
+		return func() { *x = v }
+	case *ast.SelectStmt:
+		v := *x
+		return func() { *x = v }
+	case *ast.ForStmt:
+		v := *x
+		return func() { *x = v }
+	case *ast.RangeStmt:
+		v := *x
+		return func() { *x = v }
+	case *ast.ImportSpec:
+		v := *x
+		return func() { *x = v }
+	case *ast.ValueSpec:
+		v := *x
+		return func() { *x = v }
+	case *ast.TypeSpec:
+		v := *x
+		return func() { *x = v }
+	case *ast.BadDecl:
+		v := *x
+		return func() { *x = v }
+	case *ast.GenDecl:
+		v := *x
+		return func() { *x = v }
+	case *ast.FuncDecl:
+		v := *x
+		return func() { *x = v }
+	case *ast.Comment:
+		v := *x
+		return func() { *x = v }
+	case *ast.CommentGroup:
+		v := *x
+		return func() { *x = v }
+	case *ast.File:
+		v := *x
+		return func() { *x = v }
+	case *ast.Package:
+		v := *x
+		files := make(map[string]*ast.File, len(x.Files))
+		for name, f := range x.Files {
+			files[name] = f
+		}
+		return func() {
+			*x = v
+			if x.Files == nil {
+				return
+			}
+			for name := range x.Files {
+				if _, ok := files[name]; !ok {
+					delete(x.Files, name)
+				}
+			}
+			for name, f := range files {
+				x.Files[name] = f
+			}
+		}
+	default:
+		panic("unhandled node type")
+	}
+}
+
+// saveList returns a function that restores the list field elements.
+// The field itself is restored by saveNode.
+func saveList(ptr interface{}) func() {
+	switch ptr := ptr.(type) {
+	case *[]ast.Expr:
+		v := append([]ast.Expr(nil), *ptr...)
+		return func() { copy(*ptr, v) }
+	case *[]ast.Stmt:
+		v := append([]ast.Stmt(nil), *ptr...)
+		return func() { copy(*ptr, v) }
+	case *[]ast.Decl:
+		v := append([]ast.Decl(nil), *ptr...)
+		return func() { copy(*ptr, v) }
+	case *[]ast.Spec:
+		v := append([]ast.Spec(nil), *ptr...)
+		return func() { copy(*ptr, v) }
+	case *[]*ast.Ident:
+		v := append([]*ast.Ident(nil), *ptr...)
+		return func() { copy(*ptr, v) }
+	case *[]*ast.Field:
+		v := append([]*ast.Field(nil), *ptr...)
+		return func() { copy(*ptr, v) }
+	case *[]*ast.Comment:
+		v := append([]*ast.Comment(nil), *ptr...)
+		return func() { copy(*ptr, v) }
+	case *[]*ast.ImportSpec:
+		v := append([]*ast.ImportSpec(nil), *ptr...)
+		return func() { copy(*ptr, v) }
+	case *[]*ast.CommentGroup:
+		v := append([]*ast.CommentGroup(nil), *ptr...)
+		return func() { copy(*ptr, v) }
+	default:
+		panic("unhandled list field")
+	}
+}
@@ -0,0 +1,66 @@
+package astcopy_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/go-toolsmith/strparse"
+	"github.com/vvakame/astcopy"
+)
+
+func TestCheckpoint(t *testing.T) {
+	const src = `package p
+
+import "fmt"
+
+func f(x int) int {
+	y := x * 2
+	fmt.Println(y)
+	return y
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := astcopy.File(f, nil)
+	pkg := &ast.Package{Name: "p", Files: map[string]*ast.File{"p.go": f}}
+
+	// Remember nodes to check that they stay in the tree.
+	fn := f.Decls[1].(*ast.FuncDecl)
+	body := fn.Body
+	stmts := body.List
+	assign := stmts[0].(*ast.AssignStmt)
+	mul := assign.Rhs[0].(*ast.BinaryExpr)
+
+	cp := astcopy.Checkpoint(pkg)
+	for i := 0; i < 2; i++ {
+		fn.Name.Name = "g"
+		mul.Op = token.ADD
+		mul.Y = &ast.BasicLit{Kind: token.INT, Value: "3"}
+		stmts[1] = strparse.Stmt(`panic(y)`)
+		body.List = append(body.List[:1], strparse.Stmt(`return 0`))
+		fn.Type.Results = nil
+		f.Imports[0].Path.Value = `"os"`
+		pkg.Files["q.go"] = &ast.File{Name: ast.NewIdent("p")}
+		delete(pkg.Files, "p.go")
+
+		cp.Restore()
+
+		if !astcopy.Equal(f, want) {
+			t.Fatalf("restore %d: tree is not restored", i)
+		}
+		if f.Decls[1] != fn || fn.Body != body || body.List[0] != assign || assign.Rhs[0] != mul {
+			t.Fatalf("restore %d: nodes are replaced", i)
+		}
+		if &body.List[0] != &stmts[0] {
+			t.Errorf("restore %d: statement list is reallocated", i)
+		}
+		if len(pkg.Files) != 1 || pkg.Files["p.go"] != f {
+			t.Errorf("restore %d: package files are not restored", i)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Command astcode prints Go code that constructs the AST of the given source.
+//
+// Usage:
+//
+//	astcode [-expr] [-pos] [file]
+//
+// The source is read from the file or from the standard input.
+// By default the source is parsed as a Go file, with -expr it is parsed
+// as a single expression. With -pos the generated code includes positions.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"log"
+	"os"
+
+	"github.com/vvakame/astcopy"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("astcode: ")
+
+	expr := flag.Bool("expr", false, "parse the source as an expression")
+	pos := flag.Bool("pos", false, "include positions in the generated code")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: astcode [-expr] [-pos] [file]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var (
+		filename = "<stdin>"
+		src      []byte
+		err      error
+	)
+	switch flag.NArg() {
+	case 0:
+		src, err = io.ReadAll(os.Stdin)
+	case 1:
+		filename = flag.Arg(0)
+		src, err = os.ReadFile(filename)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var node ast.Node
+	fset := token.NewFileSet()
+	if *expr {
+		node, err = parser.ParseExprFrom(fset, filename, src, 0)
+	} else {
+		node, err = parser.ParseFile(fset, filename, src, parser.ParseComments|parser.SkipObjectResolution)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var opts []astcopy.GoCodeOption
+	if *pos {
+		opts = append(opts, astcopy.GoCodePositions)
+	}
+	code, err := astcopy.GoCode(node, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Stdout.Write(code)
+	fmt.Println()
+}
@@ -0,0 +1,65 @@
+// Command astcopy copies or moves declarations between files and packages.
+//
+// Usage:
+//
+//	astcopy [-move] [-diff] -from file -to file -decl names
+//
+// The declarations named by the comma-separated -decl list, like Foo,Bar
+// or T.M for methods, are copied from the -from file to the end of the -to
+// file, which is created if it doesn't exist. With -move they are removed
+// from the -from file as well.
+//
+// Doc comments and the comments inside the declarations are kept.
+// References are requalified for the destination package, where the named
+// declarations refer to each other unqualified. The imports the copies
+// need are added to the -to file and, with -move, the imports no longer
+// used are removed from the -from file. The files are gofmt-ed.
+// Copies that would create an import cycle and methods copied to another
+// package without their receiver type fail.
+// Moving declarations still used by the rest of their package to another
+// package fails, references from other packages are not updated.
+// With -diff the changes are printed as unified diffs and no file
+// is written.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("astcopy: ")
+
+	from := flag.String("from", "", "source `file` of the declarations")
+	to := flag.String("to", "", "destination `file`, created if it doesn't exist")
+	decls := flag.String("decl", "", "comma-separated declaration `names`, methods are named T.M")
+	move := flag.Bool("move", false, "remove the declarations from the source file")
+	diff := flag.Bool("diff", false, "print unified diffs instead of writing the files")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: astcopy [-move] [-diff] -from file -to file -decl names\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 || *from == "" || *to == "" || *decls == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	changes, err := transfer(*from, *to, strings.Split(*decls, ","), *move)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, c := range changes {
+		if *diff {
+			os.Stdout.Write(unifiedDiff(c.name, c.old, c.new))
+			continue
+		}
+		if err := os.WriteFile(c.name, c.new, 0o666); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
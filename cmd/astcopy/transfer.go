@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/vvakame/astcopy/declcopy"
+)
+
+// change is a new file content.
+type change struct {
+	name     string
+	old, new []byte
+}
+
+// selected is a declaration to copy with its comments.
+type selected struct {
+	decl     ast.Decl
+	comments []*ast.CommentGroup
+	// start and end are the declaration source offsets,
+	// the doc and the trailing comments included.
+	start, end int
+}
+
+// transfer returns the file changes that copy the declarations
+// called names from the fromName file to the toName file,
+// removing them from fromName if move is set.
+func transfer(fromName, toName string, names []string, move bool) ([]change, error) {
+	fromName, err := filepath.Abs(fromName)
+	if err != nil {
+		return nil, err
+	}
+	toName, err = filepath.Abs(toName)
+	if err != nil {
+		return nil, err
+	}
+	if fromName == toName {
+		return nil, errors.New("-from and -to are the same file")
+	}
+	for i := range names {
+		names[i] = strings.TrimSpace(names[i])
+	}
+
+	fset := token.NewFileSet()
+	fromPkg, err := loadPackage(fset, filepath.Dir(fromName))
+	if err != nil {
+		return nil, err
+	}
+	if fromPkg.Types == nil || fromPkg.IllTyped {
+		return nil, fmt.Errorf("%s has errors: %v", fromPkg.PkgPath, fromPkg.Errors)
+	}
+	toPkg := fromPkg
+	samePkg := filepath.Dir(fromName) == filepath.Dir(toName)
+	if !samePkg {
+		if toPkg, err = loadPackage(fset, filepath.Dir(toName)); err != nil {
+			return nil, err
+		}
+	}
+	toTypes := toPkg.Types
+	if toTypes == nil || toTypes.Name() == "" {
+		// The destination directory has no Go files yet.
+		toTypes = types.NewPackage(toPkg.PkgPath, filepath.Base(filepath.Dir(toName)))
+	}
+
+	var fromFile *ast.File
+	for _, f := range fromPkg.Syntax {
+		if fset.File(f.FileStart).Name() == fromName {
+			fromFile = f
+		}
+	}
+	if fromFile == nil {
+		return nil, fmt.Errorf("%s is not a file of %s", fromName, fromPkg.PkgPath)
+	}
+	fromSrc, err := os.ReadFile(fromName)
+	if err != nil {
+		return nil, err
+	}
+
+	decls, err := selectDecls(fset, fromFile, names)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if !strings.Contains(name, ".") && toTypes.Scope().Lookup(name) != nil && !(samePkg && move) {
+			return nil, fmt.Errorf("%s is already declared in %s", name, toTypes.Path())
+		}
+	}
+	if move && !samePkg {
+		if err := checkUnused(fromPkg, decls); err != nil {
+			return nil, err
+		}
+	}
+
+	toChange, err := appendDecls(fset, fromPkg, toName, toTypes, decls)
+	if err != nil {
+		return nil, err
+	}
+	if !move {
+		return []change{*toChange}, nil
+	}
+	fromChange, err := removeDecls(fromName, fromSrc, decls, unusedImports(fromPkg.TypesInfo, fromFile, decls))
+	if err != nil {
+		return nil, err
+	}
+	return []change{*fromChange, *toChange}, nil
+}
+
+// loadPackage loads the package of dir with its syntax and types.
+func loadPackage(fset *token.FileSet, dir string) (*packages.Package, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps |
+			packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo,
+		Dir:  dir,
+		Fset: fset,
+	}
+	pkgs, err := packages.Load(cfg, ".")
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("%s: have %d packages, want 1", dir, len(pkgs))
+	}
+	return pkgs[0], nil
+}
+
+// selectDecls returns the f declarations called names in source order.
+// Grouped declarations must be named whole.
+func selectDecls(fset *token.FileSet, f *ast.File, names []string) ([]*selected, error) {
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = true
+	}
+	found := make(map[string]bool)
+	var decls []*selected
+	for _, decl := range f.Decls {
+		declared := declNames(decl)
+		var missing []string
+		for _, name := range declared {
+			if wanted[name] {
+				found[name] = true
+			} else {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) == len(declared) {
+			continue
+		}
+		if len(missing) != 0 {
+			return nil, fmt.Errorf("%s is declared together with %s, name all of them",
+				strings.Join(declared, ", "), strings.Join(missing, ", "))
+		}
+		decls = append(decls, newSelected(fset, f, decl))
+	}
+	for name := range wanted {
+		if !found[name] {
+			return nil, fmt.Errorf("%s is not declared in %s", name, fset.File(f.FileStart).Name())
+		}
+	}
+	return decls, nil
+}
+
+// declNames returns the names decl declares, methods are named T.M.
+func declNames(decl ast.Decl) []string {
+	var names []string
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		if decl.Recv == nil || len(decl.Recv.List) == 0 {
+			return []string{decl.Name.Name}
+		}
+		typ := decl.Recv.List[0].Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		switch x := typ.(type) {
+		case *ast.IndexExpr:
+			typ = x.X
+		case *ast.IndexListExpr:
+			typ = x.X
+		}
+		if id, ok := typ.(*ast.Ident); ok {
+			return []string{id.Name + "." + decl.Name.Name}
+		}
+	case *ast.GenDecl:
+		for _, spec := range decl.Specs {
+			switch spec := spec.(type) {
+			case *ast.ValueSpec:
+				for _, id := range spec.Names {
+					names = append(names, id.Name)
+				}
+			case *ast.TypeSpec:
+				names = append(names, spec.Name.Name)
+			}
+		}
+	}
+	return names
+}
+
+// newSelected returns decl with its comments and its source range,
+// which spans whole lines.
+func newSelected(fset *token.FileSet, f *ast.File, decl ast.Decl) *selected {
+	start, end := decl.Pos(), decl.End()
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		if decl.Doc != nil {
+			start = decl.Doc.Pos()
+		}
+	case *ast.GenDecl:
+		if decl.Doc != nil {
+			start = decl.Doc.Pos()
+		}
+	}
+	endLine := fset.Position(decl.End()).Line
+	s := &selected{decl: decl}
+	for _, cg := range f.Comments {
+		if cg.Pos() >= start && (cg.End() <= decl.End() || fset.Position(cg.Pos()).Line == endLine) {
+			s.comments = append(s.comments, cg)
+			end = max(end, cg.End())
+		}
+	}
+
+	tf := fset.File(f.FileStart)
+	s.start = tf.Offset(tf.LineStart(fset.Position(start).Line))
+	s.end = tf.Offset(end)
+	return s
+}
+
+// checkUnused returns an error if the package declarations that are not
+// moved refer to the moved ones.
+func checkUnused(pkg *packages.Package, decls []*selected) error {
+	moved := make(map[types.Object]bool)
+	inMoved := make(map[*ast.Ident]bool)
+	for _, s := range decls {
+		ast.Inspect(s.decl, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				inMoved[id] = true
+				if obj := pkg.TypesInfo.Defs[id]; obj != nil {
+					moved[obj] = true
+				}
+			}
+			return true
+		})
+	}
+	for id, obj := range pkg.TypesInfo.Uses {
+		if moved[obj] && !inMoved[id] {
+			return fmt.Errorf("%s is still used at %s", obj.Name(), pkg.Fset.Position(id.Pos()))
+		}
+	}
+	return nil
+}
+
+// appendDecls returns the change that appends the decls copies
+// to the toName file.
+func appendDecls(fset *token.FileSet, fromPkg *packages.Package, toName string, toTypes *types.Package, decls []*selected) (*change, error) {
+	old, err := os.ReadFile(toName)
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var src bytes.Buffer
+	if len(old) == 0 {
+		fmt.Fprintf(&src, "package %s\n", toTypes.Name())
+	} else {
+		src.Write(bytes.TrimRight(old, "\n"))
+		src.WriteString("\n")
+	}
+	// The declarations are all declared in the destination package,
+	// so they refer to each other unqualified.
+	var with []types.Object
+	for _, s := range decls {
+		with = append(with, declcopy.Objects(s.decl, fromPkg.TypesInfo)...)
+	}
+	deps := make(map[string]*packages.Package)
+	packages.Visit([]*packages.Package{fromPkg}, nil, func(pkg *packages.Package) {
+		deps[pkg.PkgPath] = pkg
+	})
+	var imports []declcopy.Import
+	for _, s := range decls {
+		cp, declImports, err := declcopy.Decl(s.decl, fromPkg.TypesInfo, toTypes, with, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, imp := range declImports {
+			if pkg := deps[imp.Path]; pkg != nil && importsPath(pkg, toTypes.Path()) {
+				return nil, fmt.Errorf("importing %s in %s would create an import cycle", imp.Path, toTypes.Path())
+			}
+		}
+		imports = append(imports, declImports...)
+		// The copy keeps the original positions, so the original
+		// comments are printed at their places.
+		src.WriteString("\n")
+		if err := format.Node(&src, fset, &printer.CommentedNode{Node: cp, Comments: s.comments}); err != nil {
+			return nil, err
+		}
+		src.WriteString("\n")
+	}
+
+	toFset := token.NewFileSet()
+	f, err := parser.ParseFile(toFset, toName, src.Bytes(), parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	declcopy.AddImports(toFset, f, imports)
+	var out bytes.Buffer
+	if err := format.Node(&out, toFset, f); err != nil {
+		return nil, err
+	}
+	return &change{name: toName, old: old, new: out.Bytes()}, nil
+}
+
+// importsPath reports whether pkg imports the path package,
+// directly or indirectly.
+func importsPath(pkg *packages.Package, path string) bool {
+	found := false
+	packages.Visit([]*packages.Package{pkg}, func(pkg *packages.Package) bool {
+		found = found || pkg.PkgPath == path
+		return !found
+	}, nil)
+	return found
+}
+
+// unusedImports returns the f imports used only by decls.
+// Blank and dot imports are kept.
+func unusedImports(info *types.Info, f *ast.File, decls []*selected) []*ast.ImportSpec {
+	inDecls := make(map[*ast.Ident]bool)
+	for _, s := range decls {
+		ast.Inspect(s.decl, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				inDecls[id] = true
+			}
+			return true
+		})
+	}
+	used := make(map[*types.PkgName]bool)
+	for id, obj := range info.Uses {
+		if pkgName, ok := obj.(*types.PkgName); ok && !inDecls[id] {
+			used[pkgName] = true
+		}
+	}
+	var unused []*ast.ImportSpec
+	for _, imp := range f.Imports {
+		var obj types.Object
+		if imp.Name != nil {
+			obj = info.Defs[imp.Name]
+		} else {
+			obj = info.Implicits[imp]
+		}
+		if pkgName, ok := obj.(*types.PkgName); ok && pkgName.Name() != "_" && pkgName.Name() != "." && !used[pkgName] {
+			unused = append(unused, imp)
+		}
+	}
+	return unused
+}
+
+// removeDecls returns the change that removes decls and the unused
+// imports from the src of the fromName file.
+func removeDecls(fromName string, src []byte, decls []*selected, unused []*ast.ImportSpec) (*change, error) {
+	sorted := append([]*selected(nil), decls...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].start > sorted[j].start
+	})
+	edited := append([]byte(nil), src...)
+	for _, s := range sorted {
+		end := s.end
+		if end < len(edited) && edited[end] == '\n' {
+			end++
+		}
+		edited = append(edited[:s.start], edited[end:]...)
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, fromName, edited, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	for _, imp := range unused {
+		path, err := strconv.Unquote(imp.Path.Value)
+		if err != nil {
+			continue
+		}
+		var name string
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		astutil.DeleteNamedImport(fset, f, name, path)
+	}
+	var out bytes.Buffer
+	if err := format.Node(&out, fset, f); err != nil {
+		return nil, err
+	}
+	return &change{name: fromName, old: src, new: out.Bytes()}, nil
+}
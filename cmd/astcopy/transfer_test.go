@@ -0,0 +1,262 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, src := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.22\n",
+		"a/x.go": `package a
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Prefix is prepended.
+const Prefix = "> "
+
+// Shout returns s upper-cased.
+func Shout(s string) string {
+	// Upper-case it.
+	return Prefix + strings.ToUpper(s) // done
+}
+
+// Print prints s.
+func Print(s string) { fmt.Println(s) }
+`,
+		"b/y.go": `package b
+
+import "example.com/m/a"
+
+func Use() { a.Print("x") }
+`,
+	})
+	from, to := filepath.Join(dir, "a", "x.go"), filepath.Join(dir, "b", "y.go")
+
+	changes, err := transfer(from, to, []string{"Shout"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("have %d changes, want 2", len(changes))
+	}
+	const wantFrom = `package a
+
+import (
+	"fmt"
+)
+
+// Prefix is prepended.
+const Prefix = "> "
+
+// Print prints s.
+func Print(s string) { fmt.Println(s) }
+`
+	const wantTo = `package b
+
+import (
+	"example.com/m/a"
+	"strings"
+)
+
+func Use() { a.Print("x") }
+
+// Shout returns s upper-cased.
+func Shout(s string) string {
+	// Upper-case it.
+	return a.Prefix + strings.ToUpper(s) // done
+}
+`
+	if c := changes[0]; c.name != from || string(c.new) != wantFrom {
+		t.Errorf("have %s:\n%s\nwant:\n%s", c.name, c.new, wantFrom)
+	}
+	if c := changes[1]; c.name != to || string(c.new) != wantTo {
+		t.Errorf("have %s:\n%s\nwant:\n%s", c.name, c.new, wantTo)
+	}
+
+	diff := string(unifiedDiff(from, changes[0].old, changes[0].new))
+	if !strings.HasPrefix(diff, "--- "+from+"\n+++ "+from+"\n@@ -2,17 +2,10 @@\n \n import (\n \t\"fmt\"\n-\t\"strings\"\n )\n") {
+		t.Errorf("have diff:\n%s", diff)
+	}
+
+	sameTo := filepath.Join(dir, "a", "w.go")
+	for _, tt := range []struct {
+		names []string
+		to    string
+		move  bool
+		err   string
+	}{
+		{[]string{"Nope"}, to, false, "Nope is not declared in " + from},
+		{[]string{"Prefix"}, to, true, "Prefix is still used at " + from + ":14:9"},
+		{[]string{"Shout"}, sameTo, false, "Shout is already declared in example.com/m/a"},
+		{[]string{"Shout"}, sameTo, true, ""},
+		{[]string{"Shout"}, to, false, ""},
+	} {
+		_, err := transfer(from, tt.to, tt.names, tt.move)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%v to %s: have %v error, want %q", tt.names, tt.to, err, tt.err)
+		}
+	}
+}
+
+func TestTransferTogether(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.22\n",
+		"a/a.go": `package a
+
+import "example.com/m/b"
+
+// Greeting is the greeting.
+const Greeting = "hello"
+
+// Hello greets name.
+func Hello(name string) string {
+	return Greeting + ", " + name
+}
+
+const Name = "a"
+
+// Hi greets Name.
+func Hi() string { return "hi " + Name + b.Suffix }
+
+// Bang returns the suffix.
+func Bang() string { return b.Suffix }
+`,
+		"b/b.go": `package b
+
+const Suffix = "!"
+`,
+	})
+	from, to := filepath.Join(dir, "a", "a.go"), filepath.Join(dir, "b", "b.go")
+
+	// The moved declarations refer to each other in the destination package.
+	changes, err := transfer(from, to, []string{"Hello", "Greeting"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const wantTo = `package b
+
+const Suffix = "!"
+
+// Greeting is the greeting.
+const Greeting = "hello"
+
+// Hello greets name.
+func Hello(name string) string {
+	return Greeting + ", " + name
+}
+`
+	if c := changes[1]; string(c.new) != wantTo {
+		t.Errorf("have %s:\n%s\nwant:\n%s", c.name, c.new, wantTo)
+	}
+
+	// The references to the destination package lose their qualifiers.
+	changes, err = transfer(from, to, []string{"Bang"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const wantBang = `package b
+
+const Suffix = "!"
+
+// Bang returns the suffix.
+func Bang() string { return Suffix }
+`
+	if c := changes[0]; string(c.new) != wantBang {
+		t.Errorf("have %s:\n%s\nwant:\n%s", c.name, c.new, wantBang)
+	}
+
+	// Hi refers to a.Name, but a imports b.
+	_, err = transfer(from, to, []string{"Hi"}, false)
+	if want := "importing example.com/m/a in example.com/m/b would create an import cycle"; err == nil || err.Error() != want {
+		t.Errorf("have %v error, want %q", err, want)
+	}
+}
+
+func TestTransferImports(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.22\n",
+		"a/a.go": `package a
+
+import (
+	"math/rand/v2"
+
+	"example.com/m/go-util"
+)
+
+func G() int { return rand.IntN(10) + util.One }
+
+func R() int { return rand.IntN(10) }
+`,
+		"b/b.go": "package b\n",
+		"go-util/util.go": `package util
+
+const One = 1
+`,
+	})
+	from, to := filepath.Join(dir, "a", "a.go"), filepath.Join(dir, "b", "b.go")
+
+	// The package names differ from the last import path elements.
+	changes, err := transfer(from, to, []string{"G"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const wantFrom = `package a
+
+import (
+	"math/rand/v2"
+)
+
+func R() int { return rand.IntN(10) }
+`
+	if c := changes[0]; string(c.new) != wantFrom {
+		t.Errorf("have %s:\n%s\nwant:\n%s", c.name, c.new, wantFrom)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	new := "a\nb\nc\nd\nE\nf\ng\nh\ni\nj\nk\n"
+	const want = `--- f.go
++++ f.go
+@@ -2,9 +2,10 @@
+ b
+ c
+ d
+-e
++E
+ f
+ g
+ h
+ i
+ j
++k
+`
+	if have := string(unifiedDiff("f.go", []byte(old), []byte(new))); have != want {
+		t.Errorf("have diff:\n%s\nwant:\n%s", have, want)
+	}
+	if diff := unifiedDiff("f.go", []byte(old), []byte(old)); len(diff) != 0 {
+		t.Errorf("have diff of equal contents:\n%s", diff)
+	}
+}
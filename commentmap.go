@@ -0,0 +1,68 @@
+package astcopy
+
+import (
+	"go/ast"
+)
+
+// CommentMap returns cmap for the copy at root.
+// cmap must be keyed by the original nodes nMap maps root nodes to,
+// root must be copied with nMap.
+// Both the keys and the comment groups are replaced by their copies,
+// entries with keys not copied at root are dropped as well as
+// comment groups not copied at root.
+//
+// When a node is copied more than once, like a File.Doc group that is
+// copied both as Doc and as File.Comments element by File, the
+// File.Comments element and the first copy in ast.Inspect order are used.
+func CommentMap(cmap ast.CommentMap, root ast.Node, nMap CopyNodeMap) ast.CommentMap {
+	copies := make(map[ast.Node]ast.Node)
+	record := func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		if orig, ok := nMap[n]; ok {
+			if _, ok := copies[orig]; !ok {
+				copies[orig] = n
+			}
+		}
+		return true
+	}
+	inspectFile := func(f *ast.File) {
+		if f == nil {
+			return
+		}
+		for _, cg := range f.Comments {
+			ast.Inspect(cg, record)
+		}
+		ast.Inspect(f, record)
+	}
+	switch root := root.(type) {
+	case *ast.File:
+		inspectFile(root)
+	case *ast.Package:
+		record(root)
+		for _, name := range sortedFileNames(root) {
+			inspectFile(root.Files[name])
+		}
+	default:
+		ast.Inspect(root, record)
+	}
+
+	cp := make(ast.CommentMap)
+	for n, groups := range cmap {
+		key, ok := copies[n]
+		if !ok {
+			continue
+		}
+		var cpGroups []*ast.CommentGroup
+		for _, cg := range groups {
+			if cpGroup, ok := copies[cg].(*ast.CommentGroup); ok {
+				cpGroups = append(cpGroups, cpGroup)
+			}
+		}
+		if cpGroups != nil {
+			cp[key] = cpGroups
+		}
+	}
+	return cp
+}
@@ -0,0 +1,74 @@
+package astcopy_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/vvakame/astcopy"
+)
+
+const commentMapSrc = `// Package p is documented.
+package p
+
+import "fmt" // for Println
+
+// T is a type.
+type T struct {
+	// A is a field.
+	A int // trailing A
+	B int
+}
+
+// F prints t.
+func F(t T) {
+	// Print it.
+	fmt.Println(t.A) // A only
+
+	/* dangling */
+}
+`
+
+func TestCommentMap(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", commentMapSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmap := ast.NewCommentMap(fset, f, f.Comments)
+
+	nMap := astcopy.CopyNodeMap{}
+	cp := astcopy.File(f, nMap)
+	checkCommentMap(t, "File", astcopy.CommentMap(cmap, cp, nMap), ast.NewCommentMap(fset, cp, cp.Comments))
+
+	c := astcopy.Copier{NodeMap: astcopy.CopyNodeMap{}}
+	cp = c.Copy(f).(*ast.File)
+	checkCommentMap(t, "Copier", astcopy.CommentMap(cmap, cp, c.NodeMap), ast.NewCommentMap(fset, cp, cp.Comments))
+
+	// Only the Doc group is copied with a declaration.
+	nMap = astcopy.CopyNodeMap{}
+	decl := astcopy.FuncDecl(f.Decls[2].(*ast.FuncDecl), nMap)
+	checkCommentMap(t, "FuncDecl", astcopy.CommentMap(cmap, decl, nMap), ast.CommentMap{decl: {decl.Doc}})
+}
+
+// checkCommentMap checks that have and want have the same
+// keys and comment groups.
+func checkCommentMap(t *testing.T, name string, have, want ast.CommentMap) {
+	t.Helper()
+	if len(have) != len(want) {
+		t.Fatalf("%s: have %d entries, want %d", name, len(have), len(want))
+	}
+	for n, groups := range want {
+		haveGroups := have[n]
+		if len(haveGroups) != len(groups) {
+			t.Errorf("%s: %T: have %d groups, want %d", name, n, len(haveGroups), len(groups))
+			continue
+		}
+		for i := range groups {
+			if haveGroups[i] != groups[i] {
+				t.Errorf("%s: %T: group %d differs", name, n, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package astcopy
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"strconv"
+	"strings"
+)
+
+// Copier makes deep copies without recursion.
+// It keeps nodes to copy on an explicit stack, so deeply nested trees,
+// like long string concatenation chains or else-if cascades,
+// don't grow the goroutine stack.
+//
+// The zero Copier is ready to use.
+// A Copier reuses its stack between copies and must not be used
+// concurrently.
+type Copier struct {
+	// NodeMap, if not nil, is filled like the nMap argument of Node.
+	NodeMap CopyNodeMap
+
+	// MaxNodes, if positive, limits the number of copied nodes.
+	MaxNodes int
+	// MaxDepth, if positive, limits the copied nodes depth.
+	// The copied root node depth is 1.
+	MaxDepth int
+
+	// Sharing controls copying of nodes referenced more than once.
+	Sharing SharingMode
+
+	// Validate makes copying fail with *ValidationError
+	// on malformed original nodes, see Validate.
+	Validate bool
+
+	stack []copyTask
+}
+
+// SharingMode controls copying of nodes referenced more than once,
+// like an *ast.Ident reused in a hand-built tree.
+type SharingMode int
+
+const (
+	// DuplicateShared copies shared nodes at every reference, like Node does.
+	// Cycles are copied until MaxNodes or MaxDepth limit is exceeded.
+	DuplicateShared SharingMode = iota
+	// PreserveShared copies shared nodes once, so the copy has
+	// the same sharing shape, cycles included.
+	PreserveShared
+	// RejectShared makes copying fail with *SharingError on shared nodes
+	// and cycles. File.Imports, File.Unresolved and File.Comments elements
+	// that are shared with the rest of the file are allowed and stay shared.
+	RejectShared
+)
+
+// LimitError is returned when a Copier limit is exceeded.
+type LimitError struct {
+	// Limit is the exceeded Copier field name, MaxNodes or MaxDepth.
+	Limit string
+	// Max is the exceeded limit value.
+	Max int
+	// Node is the original node that exceeds the limit.
+	Node ast.Node
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("astcopy: %s limit %d exceeded at %s node", e.Limit, e.Max, nodeName(e.Node))
+}
+
+// SharingError is returned in RejectShared mode when a node
+// is referenced more than once.
+type SharingError struct {
+	// Node is the original shared node.
+	Node ast.Node
+	// Path is the rejected reference path from the copied root,
+	// like "File.Decls[0].Body.List[1]".
+	Path string
+	// FirstPath is the path the node was copied at.
+	FirstPath string
+	// Cycle reports whether the node is its own ancestor at Path.
+	Cycle bool
+}
+
+func (e *SharingError) Error() string {
+	if e.Cycle {
+		return fmt.Sprintf("astcopy: cycle: %s node at %s refers to its ancestor at %s",
+			nodeName(e.Node), e.Path, e.FirstPath)
+	}
+	return fmt.Sprintf("astcopy: %s node at %s is shared with %s",
+		nodeName(e.Node), e.Path, e.FirstPath)
+}
+
+// ctxCheckInterval is the number of nodes copied between
+// context cancellation checks.
+const ctxCheckInterval = 1024
+
+// copyTask is a node to copy and the field to store the copy in.
+type copyTask struct {
+	x     ast.Node
+	dst   interface{}
+	depth int
+	// alias is set for the File fields that duplicate other references.
+	alias bool
+	// path is only tracked in RejectShared mode and with Validate.
+	path *copyPath
+}
+
+// copyPath is a node reference path element.
+type copyPath struct {
+	parent *copyPath
+	x      ast.Node
+	field  string
+	index  int
+}
+
+func (p *copyPath) String() string {
+	var elems []string
+	for ; p != nil; p = p.parent {
+		switch {
+		case p.parent == nil:
+			elems = append(elems, nodeName(p.x))
+		case p.index >= 0:
+			elems = append(elems, "."+p.field+"["+strconv.Itoa(p.index)+"]")
+		default:
+			elems = append(elems, "."+p.field)
+		}
+	}
+	var b strings.Builder
+	for i := len(elems) - 1; i >= 0; i-- {
+		b.WriteString(elems[i])
+	}
+	return b.String()
+}
+
+// copyState is the state shared by a CopyContext call.
+type copyState struct {
+	ctx        context.Context
+	count      int
+	trackPaths bool
+	// copies maps original nodes to their copies
+	// in PreserveShared and RejectShared modes.
+	copies map[ast.Node]ast.Node
+	// paths maps original nodes to their paths in RejectShared mode.
+	paths map[ast.Node]*copyPath
+}
+
+// Copy returns x deep copy.
+// Copy of nil argument is nil.
+//
+// Unlike Node, Copy deep copies Package files.
+// Copy panics with the error CopyContext would return.
+func (c *Copier) Copy(x ast.Node) ast.Node {
+	cp, err := c.CopyContext(context.Background(), x)
+	if err != nil {
+		panic(err)
+	}
+	return cp
+}
+
+// CopyContext returns x deep copy like Copy does.
+// It returns *LimitError if a limit is exceeded, *SharingError
+// for shared nodes in RejectShared mode, *ValidationError for malformed
+// nodes with Validate and the ctx error
+// if ctx is done before the copy is complete.
+// NodeMap may be partially filled on errors.
+func (c *Copier) CopyContext(ctx context.Context, x ast.Node) (ast.Node, error) {
+	s := &copyState{ctx: ctx}
+	if c.Sharing != DuplicateShared {
+		s.copies = make(map[ast.Node]ast.Node)
+	}
+	if c.Sharing == RejectShared {
+		s.paths = make(map[ast.Node]*copyPath)
+	}
+	s.trackPaths = c.Sharing == RejectShared || c.Validate
+	var path *copyPath
+	if s.trackPaths {
+		path = &copyPath{x: orNil(x), index: -1}
+	}
+	return c.copy(s, x, 1, path)
+}
+
+func (c *Copier) copy(s *copyState, x ast.Node, depth int, path *copyPath) (root ast.Node, err error) {
+	x = orNil(x)
+	if x == nil {
+		return nil, nil
+	}
+
+	stack := append(c.stack[:0], copyTask{x: x, depth: depth, path: path})
+	used := 0
+	defer func() {
+		// The reused stack must not keep the nodes alive.
+		clear(stack[:used])
+		c.stack = stack[:0]
+	}()
+	for len(stack) != 0 {
+		used = max(used, len(stack))
+		t := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if cp, ok := s.copies[t.x]; ok {
+			if s.paths != nil && !t.alias {
+				return nil, sharingError(t, s.paths[t.x])
+			}
+			if t.dst == nil {
+				root = cp
+			} else {
+				setNode(t.dst, cp)
+			}
+			continue
+		}
+
+		s.count++
+		if c.MaxNodes > 0 && s.count > c.MaxNodes {
+			return nil, &LimitError{Limit: "MaxNodes", Max: c.MaxNodes, Node: t.x}
+		}
+		if c.MaxDepth > 0 && t.depth > c.MaxDepth {
+			return nil, &LimitError{Limit: "MaxDepth", Max: c.MaxDepth, Node: t.x}
+		}
+		if s.count%ctxCheckInterval == 0 {
+			if err := s.ctx.Err(); err != nil {
+				return nil, fmt.Errorf("astcopy: copy canceled: %w", err)
+			}
+		}
+
+		if c.Validate {
+			if problem := checkNode(t.x); problem != "" {
+				return nil, &ValidationError{Node: t.x, Path: t.path.String(), Problem: problem}
+			}
+		}
+
+		cp := shallowCopy(t.x)
+		if t.dst == nil {
+			root = cp
+		} else {
+			setNode(t.dst, cp)
+		}
+		if c.NodeMap != nil {
+			base := c.NodeMap[t.x]
+			if base == nil {
+				base = t.x
+			}
+			c.NodeMap[cp] = base
+		}
+		if s.copies != nil {
+			s.copies[t.x] = cp
+		}
+		if s.paths != nil {
+			s.paths[t.x] = t.path
+		}
+
+		switch cp.(type) {
+		case *ast.Ident, *ast.BasicLit, *ast.Comment:
+			// Leaves are copied without the fields table.
+			continue
+		}
+		_, isFile := cp.(*ast.File)
+		n := len(stack)
+		for _, f := range nodeFields(cp) {
+			child := copyTask{depth: t.depth + 1}
+			if isFile {
+				switch f.name {
+				case "Imports", "Unresolved", "Comments":
+					child.alias = true
+				}
+			}
+			switch {
+			case isNodeField(f.ptr):
+				if child.x = getNode(f.ptr); child.x != nil {
+					child.dst = f.ptr
+					if s.trackPaths {
+						child.path = &copyPath{parent: t.path, x: child.x, field: f.name, index: -1}
+					}
+					stack = append(stack, child)
+				}
+			case isListField(f.ptr):
+				// The copy shares the slice with the original,
+				// so the slice is replaced before the elements are.
+				for i, n := 0, cloneList(f.ptr); i < n; i++ {
+					elem := listElem(f.ptr, i)
+					child.x = getNode(elem)
+					if s.trackPaths {
+						child.path = &copyPath{parent: t.path, x: child.x, field: f.name, index: i}
+					}
+					if child.x == nil {
+						if c.Validate {
+							return nil, &ValidationError{Path: child.path.String(), Problem: "nil list element"}
+						}
+						continue
+					}
+					child.dst = elem
+					stack = append(stack, child)
+				}
+			}
+		}
+		// Children are reversed to be popped in the source order.
+		for i, j := n, len(stack)-1; i < j; i, j = i+1, j-1 {
+			stack[i], stack[j] = stack[j], stack[i]
+		}
+	}
+
+	// Packages are never nested, so files are copied with one more
+	// copy call depth.
+	if pkg, ok := root.(*ast.Package); ok && pkg.Files != nil {
+		names := sortedFileNames(pkg)
+		files := pkg.Files
+		pkg.Files = make(map[string]*ast.File, len(files))
+		for _, name := range names {
+			f := files[name]
+			if f == nil {
+				pkg.Files[name] = nil
+				continue
+			}
+			var filePath *copyPath
+			if s.trackPaths {
+				filePath = &copyPath{parent: path, x: f, field: "Files[" + strconv.Quote(name) + "]", index: -1}
+			}
+			cp, err := c.copy(s, f, depth+1, filePath)
+			if err != nil {
+				return nil, err
+			}
+			pkg.Files[name] = cp.(*ast.File)
+		}
+	}
+	return root, nil
+}
+
+// sharingError returns an error for t node that was copied at first path.
+func sharingError(t copyTask, first *copyPath) *SharingError {
+	err := &SharingError{Node: t.x, Path: t.path.String(), FirstPath: first.String()}
+	for p := t.path.parent; p != nil; p = p.parent {
+		if p.x == t.x {
+			err.Cycle = true
+			break
+		}
+	}
+	return err
+}
@@ -0,0 +1,276 @@
+package astcopy_test
+
+import (
+	"context"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"testing"
+
+	"github.com/vvakame/astcopy"
+)
+
+func TestCopier(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(runtime.GOROOT(), "src", "go", "*", "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	pkg := &ast.Package{Name: "std", Files: map[string]*ast.File{}}
+	for _, name := range files {
+		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg.Files[name] = f
+	}
+
+	origs := make(map[ast.Node]bool)
+	for _, f := range pkg.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			origs[n] = true
+			return true
+		})
+	}
+
+	c := astcopy.Copier{NodeMap: astcopy.CopyNodeMap{}}
+	cp := c.Copy(pkg).(*ast.Package)
+	if len(cp.Files) != len(pkg.Files) {
+		t.Fatalf("have %d files, want %d", len(cp.Files), len(pkg.Files))
+	}
+	for name, f := range pkg.Files {
+		cpFile := cp.Files[name]
+		if !astcopy.Equal(f, cpFile) {
+			t.Errorf("%s: copy differs", name)
+		}
+		ast.Inspect(cpFile, func(n ast.Node) bool {
+			if n == nil {
+				return false
+			}
+			if origs[n] {
+				t.Fatalf("%s: %T node is not copied", name, n)
+			}
+			if orig := c.NodeMap[n]; !origs[orig] {
+				t.Fatalf("%s: %T node is not mapped to its original", name, n)
+			}
+			return true
+		})
+	}
+}
+
+func TestCopierDeep(t *testing.T) {
+	const depth = 100000
+
+	var x ast.Expr = &ast.BasicLit{Kind: token.STRING, Value: `"0"`}
+	for i := 1; i < depth; i++ {
+		x = &ast.BinaryExpr{X: x, Op: token.ADD, Y: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(strconv.Itoa(i))}}
+	}
+	var stmt ast.Stmt = &ast.BlockStmt{}
+	for i := 0; i < depth; i++ {
+		stmt = &ast.IfStmt{Cond: ast.NewIdent("ok"), Body: &ast.BlockStmt{}, Else: stmt}
+	}
+
+	// The recursive copy of these trees needs much more stack.
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+
+	var c astcopy.Copier
+	cpX := c.Copy(x)
+	n := 0
+	for e, ok := cpX.(*ast.BinaryExpr); ok; e, ok = e.X.(*ast.BinaryExpr) {
+		n++
+	}
+	if n != depth-1 {
+		t.Errorf("have %d binary expressions, want %d", n, depth-1)
+	}
+	if cpX.(*ast.BinaryExpr).Y.(*ast.BasicLit).Value != strconv.Quote(strconv.Itoa(depth-1)) {
+		t.Error("binary expression operands are not copied")
+	}
+
+	cpStmt := c.Copy(stmt)
+	n = 0
+	for s, ok := cpStmt.(*ast.IfStmt); ok; s, ok = s.Else.(*ast.IfStmt) {
+		n++
+	}
+	if n != depth {
+		t.Errorf("have %d if statements, want %d", n, depth)
+	}
+}
+
+func TestCopierLimits(t *testing.T) {
+	x := deepBinaryExpr(100) // 199 nodes, 100 levels deep.
+
+	for _, test := range []struct {
+		c     astcopy.Copier
+		limit string
+	}{
+		{astcopy.Copier{MaxNodes: 199, MaxDepth: 100}, ""},
+		{astcopy.Copier{MaxNodes: 198}, "MaxNodes"},
+		{astcopy.Copier{MaxDepth: 99}, "MaxDepth"},
+	} {
+		cp, err := test.c.CopyContext(context.Background(), x)
+		if test.limit == "" {
+			if err != nil || !astcopy.Equal(x, cp) {
+				t.Errorf("%+v: have %v error, want a copy", test.c, err)
+			}
+			continue
+		}
+		var limitErr *astcopy.LimitError
+		if !errors.As(err, &limitErr) || limitErr.Limit != test.limit {
+			t.Errorf("%+v: have %v error, want %s limit error", test.c, err, test.limit)
+			continue
+		}
+		if cp != nil {
+			t.Errorf("%+v: have a copy with the error", test.c)
+		}
+		if limitErr.Node == nil {
+			t.Errorf("%+v: the error has no node", test.c)
+		}
+	}
+
+	// Limits are shared by package files.
+	pkg := &ast.Package{Files: map[string]*ast.File{
+		"a.go": {Name: ast.NewIdent("a")},
+		"b.go": {Name: ast.NewIdent("b")},
+	}}
+	c := astcopy.Copier{MaxNodes: 4}
+	if _, err := c.CopyContext(context.Background(), pkg); err == nil {
+		t.Error("package files node limit is not applied")
+	}
+	c = astcopy.Copier{MaxDepth: 2}
+	if _, err := c.CopyContext(context.Background(), pkg); err == nil {
+		t.Error("package files depth limit is not applied")
+	}
+
+	defer func() {
+		if _, ok := recover().(*astcopy.LimitError); !ok {
+			t.Error("Copy does not panic with the limit error")
+		}
+	}()
+	c = astcopy.Copier{MaxNodes: 1}
+	c.Copy(x)
+}
+
+func TestCopierCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var c astcopy.Copier
+	_, err := c.CopyContext(ctx, deepBinaryExpr(10000))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("have %v error, want context.Canceled", err)
+	}
+}
+
+func TestCopierSharing(t *testing.T) {
+	id := ast.NewIdent("x")
+	shared := &ast.BinaryExpr{X: id, Op: token.ADD, Y: &ast.ParenExpr{X: id}}
+
+	cycle := &ast.ParenExpr{}
+	cycle.X = &ast.StarExpr{X: cycle}
+
+	c := astcopy.Copier{Sharing: astcopy.DuplicateShared}
+	cp := c.Copy(shared).(*ast.BinaryExpr)
+	if cp.X == cp.Y.(*ast.ParenExpr).X {
+		t.Error("DuplicateShared: shared node is not duplicated")
+	}
+
+	c = astcopy.Copier{Sharing: astcopy.PreserveShared, NodeMap: astcopy.CopyNodeMap{}}
+	cp = c.Copy(shared).(*ast.BinaryExpr)
+	if cp.X != cp.Y.(*ast.ParenExpr).X || cp.X == id || c.NodeMap[cp.X] != id {
+		t.Error("PreserveShared: shared node is not copied once")
+	}
+	cpCycle := c.Copy(cycle).(*ast.ParenExpr)
+	if cpCycle == cycle || cpCycle.X.(*ast.StarExpr).X != cpCycle {
+		t.Error("PreserveShared: cycle is not copied")
+	}
+
+	c = astcopy.Copier{Sharing: astcopy.RejectShared}
+	for _, test := range []struct {
+		x     ast.Node
+		err   string
+		cycle bool
+	}{
+		{shared, "astcopy: Ident node at BinaryExpr.Y.X is shared with BinaryExpr.X", false},
+		{cycle, "astcopy: cycle: ParenExpr node at ParenExpr.X.X refers to its ancestor at ParenExpr", true},
+	} {
+		_, err := c.CopyContext(context.Background(), test.x)
+		var sharingErr *astcopy.SharingError
+		if !errors.As(err, &sharingErr) {
+			t.Errorf("RejectShared: have %v error, want sharing error", err)
+			continue
+		}
+		if err.Error() != test.err || sharingErr.Cycle != test.cycle {
+			t.Errorf("RejectShared: have %q error, want %q", err, test.err)
+		}
+	}
+
+	// Parsed files File.Imports and File.Comments are shared
+	// with declarations and are not rejected.
+	const src = `package p
+
+// Doc.
+import "fmt"
+
+func f() { fmt.Println() }
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpFile, err := c.CopyContext(context.Background(), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := cpFile.(*ast.File)
+	decl := file.Decls[0].(*ast.GenDecl)
+	if file.Imports[0] != decl.Specs[0] || file.Comments[0] != decl.Doc {
+		t.Error("RejectShared: file references are not shared")
+	}
+
+	f.Decls = append(f.Decls, f.Decls[1])
+	_, err = c.CopyContext(context.Background(), f)
+	if want := "astcopy: FuncDecl node at File.Decls[2] is shared with File.Decls[1]"; err == nil || err.Error() != want {
+		t.Errorf("RejectShared: have %v error, want %q", err, want)
+	}
+}
+
+func deepBinaryExpr(depth int) ast.Expr {
+	var x ast.Expr = ast.NewIdent("s")
+	for i := 1; i < depth; i++ {
+		x = &ast.BinaryExpr{X: x, Op: token.ADD, Y: &ast.BasicLit{Kind: token.STRING, Value: `"x"`}}
+	}
+	return x
+}
+
+func BenchmarkDeepBinaryExpr(b *testing.B) {
+	for _, depth := range []int{100, 10000} {
+		x := deepBinaryExpr(depth)
+		b.Run("Node/"+strconv.Itoa(depth), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				astcopy.Node(x, nil)
+			}
+		})
+		b.Run("Copier/"+strconv.Itoa(depth), func(b *testing.B) {
+			var c astcopy.Copier
+			for i := 0; i < b.N; i++ {
+				c.Copy(x)
+			}
+		})
+	}
+}
+
+func TestCopierTypedNil(t *testing.T) {
+	// Typed nil children are skipped like untyped nil ones.
+	var id *ast.Ident
+	x := &ast.ExprStmt{X: &ast.ParenExpr{X: id}}
+	var c astcopy.Copier
+	cp := c.Copy(x).(*ast.ExprStmt)
+	if cp == x || cp.X == x.X {
+		t.Error("copy shares nodes with the original")
+	}
+}
@@ -0,0 +1,351 @@
+// Package declcopy copies declarations between packages.
+//
+// The copies are requalified with the go/types information of the source
+// package, so references keep denoting the same objects
+// in the destination package.
+package declcopy
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/go/ast/astutil"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/vvakame/astcopy"
+)
+
+// Import is an import a copied declaration needs.
+type Import struct {
+	// Name is the import name, it is empty if the package name is used.
+	Name string
+	// Path is the package path.
+	Path string
+}
+
+// Decl returns x copy requalified for the to package and the imports
+// the copy needs in the destination file, sorted by path.
+// info must be the types info of the x package.
+// with are the objects copied to the to package together with x,
+// like the ones returned by Objects for the other copied declarations.
+//
+// Unqualified references to package-level objects of other packages,
+// like the ones of the x package, get qualified by the package name,
+// and qualified references to the to package objects lose the qualifier.
+// Other qualified references keep their qualifiers.
+// References to the objects x declares and to the with objects
+// stay unqualified.
+// Local declarations shadowing the added qualifiers are not detected.
+// Packages are compared by path, so to may come from another load
+// than the info packages, or be a new package for an empty directory.
+//
+// Decl returns an error if x refers to unexported objects
+// of other packages, the copy can't refer to them, and if x is a method
+// copied to another package without its receiver type.
+func Decl(x ast.Decl, info *types.Info, to *types.Package, with []types.Object, nMap astcopy.CopyNodeMap) (ast.Decl, []Import, error) {
+	declared := make(map[types.Object]bool)
+	for _, obj := range append(Objects(x, info), with...) {
+		declared[obj] = true
+	}
+	if fn, ok := x.(*ast.FuncDecl); ok {
+		if recv := recvTypeName(info.Defs[fn.Name]); recv != nil && !samePackage(recv.Pkg(), to) && !declared[recv] {
+			return nil, nil, fmt.Errorf("declcopy: method %s.%s is copied without its receiver type", recv.Name(), fn.Name.Name)
+		}
+	}
+
+	local := astcopy.CopyNodeMap{}
+	cp := astcopy.Decl(x, local)
+	q := &qualifier{
+		info:     info,
+		to:       to,
+		nMap:     local,
+		declared: declared,
+		imports:  make(map[Import]bool),
+	}
+	astutil.Apply(cp, q.pre, nil)
+	if q.err != nil {
+		return nil, nil, q.err
+	}
+
+	if nMap != nil {
+		for n, orig := range local {
+			base := nMap[orig]
+			if base == nil {
+				base = orig
+			}
+			nMap[n] = base
+		}
+	}
+	return cp, sortedImports(q.imports), nil
+}
+
+// AddImports adds imports to f with astutil.AddNamedImport.
+// It reports whether any import is added.
+func AddImports(fset *token.FileSet, f *ast.File, imports []Import) bool {
+	added := false
+	for _, imp := range imports {
+		if astutil.AddNamedImport(fset, f, imp.Name, imp.Path) {
+			added = true
+		}
+	}
+	return added
+}
+
+// Objects returns the package-level objects x declares, methods included.
+// info must be the types info of the x package.
+func Objects(x ast.Decl, info *types.Info) []types.Object {
+	var objs []types.Object
+	record := func(id *ast.Ident) {
+		if obj := info.Defs[id]; obj != nil {
+			objs = append(objs, obj)
+		}
+	}
+	switch x := x.(type) {
+	case *ast.FuncDecl:
+		record(x.Name)
+	case *ast.GenDecl:
+		for _, spec := range x.Specs {
+			switch spec := spec.(type) {
+			case *ast.ValueSpec:
+				for _, id := range spec.Names {
+					record(id)
+				}
+			case *ast.TypeSpec:
+				record(spec.Name)
+			}
+		}
+	}
+	return objs
+}
+
+// isPackageLevel reports whether obj is declared in its package scope.
+func isPackageLevel(obj types.Object) bool {
+	return obj.Pkg() != nil && obj.Parent() == obj.Pkg().Scope()
+}
+
+// samePackage reports whether x and y are the same package,
+// possibly type checked separately.
+func samePackage(x, y *types.Package) bool {
+	return x.Path() == y.Path()
+}
+
+// qualifier rewrites the references of a declaration copy.
+type qualifier struct {
+	info     *types.Info
+	to       *types.Package
+	nMap     astcopy.CopyNodeMap
+	declared map[types.Object]bool
+	imports  map[Import]bool
+	err      error
+}
+
+func (q *qualifier) pre(c *astutil.Cursor) bool {
+	if q.err != nil {
+		return false
+	}
+	switch n := c.Node().(type) {
+	case *ast.SelectorExpr:
+		pkgID, ok := n.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		pkgName, ok := q.info.Uses[q.orig(pkgID)].(*types.PkgName)
+		if !ok {
+			return true
+		}
+		imported := pkgName.Imported()
+		if samePackage(imported, q.to) {
+			c.Replace(n.Sel)
+			return false
+		}
+		imp := Import{Path: imported.Path()}
+		if pkgID.Name != imported.Name() {
+			imp.Name = pkgID.Name
+		}
+		q.imports[imp] = true
+		return false
+	case *ast.Ident:
+		obj := q.info.Uses[q.orig(n)]
+		if obj == nil || !isPackageLevel(obj) || samePackage(obj.Pkg(), q.to) || q.declared[obj] {
+			return true
+		}
+		if !obj.Exported() {
+			q.err = fmt.Errorf("declcopy: %s refers to unexported %s.%s", n.Name, obj.Pkg().Path(), obj.Name())
+			return false
+		}
+		c.Replace(&ast.SelectorExpr{X: &ast.Ident{NamePos: n.NamePos, Name: obj.Pkg().Name()}, Sel: n})
+		q.imports[Import{Path: obj.Pkg().Path()}] = true
+	}
+	return true
+}
+
+// orig returns the original of the x copy.
+func (q *qualifier) orig(x *ast.Ident) *ast.Ident {
+	orig, _ := q.nMap[x].(*ast.Ident)
+	return orig
+}
+
+// sortedImports returns the imports of set sorted by path.
+func sortedImports(set map[Import]bool) []Import {
+	imports := make([]Import, 0, len(set))
+	for imp := range set {
+		imports = append(imports, imp)
+	}
+	sort.Slice(imports, func(i, j int) bool {
+		if imports[i].Path != imports[j].Path {
+			return imports[i].Path < imports[j].Path
+		}
+		return imports[i].Name < imports[j].Name
+	})
+	return imports
+}
+
+// Extract returns a new file of the pkg package with copies of x
+// and of all the package-level declarations x refers to, directly
+// or indirectly, with the imports they need.
+// Methods of the copied types are copied too, so the types keep
+// implementing the same interfaces.
+// pkg must have Fset, Syntax, Types and TypesInfo loaded.
+//
+// The declarations keep their source order, grouped declarations are
+// copied whole, and the comments inside them are copied as well.
+// The copies keep their positions, so the file can be printed
+// with pkg.Fset.
+func Extract(pkg *packages.Package, x ast.Decl, nMap astcopy.CopyNodeMap) (*ast.File, error) {
+	info := pkg.TypesInfo
+	decls := make(map[types.Object]ast.Decl)
+	methods := make(map[*types.TypeName][]ast.Decl)
+	files := make(map[ast.Decl]*ast.File)
+	for _, f := range pkg.Syntax {
+		for _, decl := range f.Decls {
+			files[decl] = f
+			for _, obj := range Objects(decl, info) {
+				decls[obj] = decl
+				if recv := recvTypeName(obj); recv != nil {
+					methods[recv] = append(methods[recv], decl)
+				}
+			}
+		}
+	}
+
+	included := map[ast.Decl]bool{x: true}
+	queue := []ast.Decl{x}
+	include := func(decl ast.Decl) {
+		if decl != nil && !included[decl] {
+			included[decl] = true
+			queue = append(queue, decl)
+		}
+	}
+	for len(queue) != 0 {
+		decl := queue[0]
+		queue = queue[1:]
+		for _, obj := range Objects(decl, info) {
+			if tn, ok := obj.(*types.TypeName); ok {
+				for _, method := range methods[tn] {
+					include(method)
+				}
+			}
+		}
+		ast.Inspect(decl, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				if obj := info.Uses[id]; obj != nil && obj.Pkg() == pkg.Types {
+					include(decls[origin(obj)])
+				}
+			}
+			return true
+		})
+	}
+
+	sorted := make([]ast.Decl, 0, len(included))
+	for decl := range included {
+		sorted = append(sorted, decl)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Pos() < sorted[j].Pos()
+	})
+
+	// The package clause position is taken from the x file,
+	// astutil.AddNamedImport needs it.
+	f := &ast.File{Name: ast.NewIdent(pkg.Types.Name())}
+	src := files[x]
+	if src == nil && len(pkg.Syntax) != 0 {
+		src = pkg.Syntax[0]
+	}
+	if src != nil {
+		f.Package = src.Package
+		f.Name.NamePos = src.Name.NamePos
+	}
+	imports := make(map[Import]bool)
+	for _, decl := range sorted {
+		cp, declImports, err := Decl(decl, info, pkg.Types, nil, nMap)
+		if err != nil {
+			return nil, err
+		}
+		f.Decls = append(f.Decls, cp)
+		for _, imp := range declImports {
+			imports[imp] = true
+		}
+		if src := files[decl]; src != nil {
+			f.Comments = append(f.Comments, declComments(src, decl, nMap)...)
+		}
+	}
+	AddImports(pkg.Fset, f, sortedImports(imports))
+	return f, nil
+}
+
+// recvTypeName returns the receiver base type name of a method obj.
+func recvTypeName(obj types.Object) *types.TypeName {
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return nil
+	}
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return nil
+	}
+	typ := recv.Type()
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	if named, ok := typ.(*types.Named); ok {
+		return named.Origin().Obj()
+	}
+	return nil
+}
+
+// origin returns the generic object obj is instantiated from.
+func origin(obj types.Object) types.Object {
+	switch obj := obj.(type) {
+	case *types.Func:
+		return obj.Origin()
+	case *types.Var:
+		return obj.Origin()
+	}
+	return obj
+}
+
+// declComments returns copies of the f comments of decl,
+// its doc comment included.
+func declComments(f *ast.File, decl ast.Decl, nMap astcopy.CopyNodeMap) []*ast.CommentGroup {
+	start := decl.Pos()
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		if decl.Doc != nil {
+			start = decl.Doc.Pos()
+		}
+	case *ast.GenDecl:
+		if decl.Doc != nil {
+			start = decl.Doc.Pos()
+		}
+	}
+	var groups []*ast.CommentGroup
+	for _, cg := range f.Comments {
+		if cg.Pos() >= start && cg.End() <= decl.End() {
+			groups = append(groups, astcopy.CommentGroup(cg, nMap))
+		}
+	}
+	return groups
+}
@@ -0,0 +1,326 @@
+package declcopy_test
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/vvakame/astcopy"
+	"github.com/vvakame/astcopy/declcopy"
+)
+
+// testPackages are type checked in order, the later ones can import
+// the earlier ones.
+var testPackages = []struct {
+	path, src string
+}{
+	{"example.com/util", `package util
+
+func Join(s ...string) string { return "" }
+`},
+	{"example.com/b", `package b
+
+type Name string
+
+const Sep = "-"
+`},
+	{"example.com/a", `package a
+
+import (
+	"example.com/b"
+	u "example.com/util"
+)
+
+// Greeter greets.
+type Greeter struct {
+	Prefix b.Name
+}
+
+const Default = "hi"
+
+// Greet returns the greeting.
+func Greet(g Greeter, name b.Name) string {
+	if name == "" {
+		return Greet(g, Default)
+	}
+	return u.Join(string(g.Prefix), b.Sep, string(name))
+}
+
+func helper() {}
+
+func Broken() { helper() }
+
+// Hello says hello.
+func (g *Greeter) Hello() string { return Greet(*g, Default) }
+`},
+}
+
+type testPackage struct {
+	file *ast.File
+	pkg  *types.Package
+	info *types.Info
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+func checkPackages(t *testing.T, fset *token.FileSet) map[string]*testPackage {
+	t.Helper()
+	pkgs := make(map[string]*testPackage)
+	conf := types.Config{Importer: importerFunc(func(path string) (*types.Package, error) {
+		if p, ok := pkgs[path]; ok {
+			return p.pkg, nil
+		}
+		return nil, fmt.Errorf("package %s not found", path)
+	})}
+	for _, p := range testPackages {
+		f, err := parser.ParseFile(fset, p.path+".go", p.src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := &types.Info{
+			Defs: make(map[*ast.Ident]types.Object),
+			Uses: make(map[*ast.Ident]types.Object),
+		}
+		pkg, err := conf.Check(p.path, fset, []*ast.File{f}, info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkgs[p.path] = &testPackage{file: f, pkg: pkg, info: info}
+	}
+	return pkgs
+}
+
+func findDecl(f *ast.File, name string) ast.Decl {
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Name.Name == name {
+				return decl
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				if spec, ok := spec.(*ast.TypeSpec); ok && spec.Name.Name == name {
+					return decl
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func formatNode(t *testing.T, fset *token.FileSet, x any) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, x); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestDecl(t *testing.T) {
+	fset := token.NewFileSet()
+	pkgs := checkPackages(t, fset)
+	a, b := pkgs["example.com/a"], pkgs["example.com/b"]
+
+	orig := findDecl(a.file, "Greet")
+	want := formatNode(t, fset, orig)
+	nMap := astcopy.CopyNodeMap{}
+	cp, imports, err := declcopy.Decl(orig, a.info, b.pkg, nil, nMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := formatNode(t, fset, orig); have != want {
+		t.Errorf("original is modified:\n%s", have)
+	}
+
+	const wantCopy = `// Greet returns the greeting.
+func Greet(g a.Greeter, name Name) string {
+	if name == "" {
+		return Greet(g, a.Default)
+	}
+	return u.Join(string(g.Prefix), Sep, string(name))
+}`
+	if have := formatNode(t, fset, cp); have != wantCopy {
+		t.Errorf("have copy:\n%s\nwant:\n%s", have, wantCopy)
+	}
+	wantImports := []declcopy.Import{{Path: "example.com/a"}, {Name: "u", Path: "example.com/util"}}
+	if !reflect.DeepEqual(imports, wantImports) {
+		t.Errorf("have %v imports, want %v", imports, wantImports)
+	}
+	if nMap[cp] != orig {
+		t.Error("copy is not mapped to its original")
+	}
+
+	b.file.Decls = append(b.file.Decls, cp)
+	if !declcopy.AddImports(fset, b.file, imports) {
+		t.Error("no imports added")
+	}
+	if declcopy.AddImports(fset, b.file, imports) {
+		t.Error("imports added twice")
+	}
+	if have := formatNode(t, fset, b.file.Decls[0]); have != "import (\n\t\"example.com/a\"\n\tu \"example.com/util\"\n)" {
+		t.Errorf("have imports:\n%s", have)
+	}
+
+	// The copy to the same package is not changed.
+	cp, _, err = declcopy.Decl(orig, a.info, a.pkg, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := formatNode(t, fset, cp); have != want {
+		t.Errorf("have same package copy:\n%s", have)
+	}
+
+	// The destination package is found by path, a separately
+	// checked one is equivalent.
+	cp, _, err = declcopy.Decl(orig, a.info, types.NewPackage(b.pkg.Path(), b.pkg.Name()), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := formatNode(t, fset, cp); have != wantCopy {
+		t.Errorf("have separately checked package copy:\n%s", have)
+	}
+
+	_, _, err = declcopy.Decl(findDecl(a.file, "Broken"), a.info, b.pkg, nil, nil)
+	if want := "declcopy: helper refers to unexported example.com/a.helper"; err == nil || err.Error() != want {
+		t.Errorf("have %v error, want %q", err, want)
+	}
+}
+
+func TestDeclWith(t *testing.T) {
+	fset := token.NewFileSet()
+	pkgs := checkPackages(t, fset)
+	a, b := pkgs["example.com/a"], pkgs["example.com/b"]
+
+	// The declarations copied together refer to each other unqualified.
+	greeter, hello := findDecl(a.file, "Greeter"), findDecl(a.file, "Hello")
+	var with []types.Object
+	for _, decl := range []ast.Decl{greeter, hello, findDecl(a.file, "Greet")} {
+		with = append(with, declcopy.Objects(decl, a.info)...)
+	}
+	cp, imports, err := declcopy.Decl(hello, a.info, b.pkg, with, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `// Hello says hello.
+func (g *Greeter) Hello() string { return Greet(*g, a.Default) }`
+	if have := formatNode(t, fset, cp); have != want {
+		t.Errorf("have copy:\n%s\nwant:\n%s", have, want)
+	}
+	if wantImports := []declcopy.Import{{Path: "example.com/a"}}; !reflect.DeepEqual(imports, wantImports) {
+		t.Errorf("have %v imports, want %v", imports, wantImports)
+	}
+
+	// The receiver type of a method can't be qualified.
+	_, _, err = declcopy.Decl(hello, a.info, b.pkg, nil, nil)
+	if want := "declcopy: method Greeter.Hello is copied without its receiver type"; err == nil || err.Error() != want {
+		t.Errorf("have %v error, want %q", err, want)
+	}
+	if _, _, err := declcopy.Decl(hello, a.info, a.pkg, nil, nil); err != nil {
+		t.Errorf("same package copy: %v", err)
+	}
+}
+
+const extractSrc = `package c
+
+import (
+	u "example.com/util"
+)
+
+// Limit is the maximum.
+const Limit = 10
+
+// Item is a named item.
+type Item struct {
+	Name string
+}
+
+func (i Item) String() string { return u.Join(i.Name) }
+
+var defaultItems = []Item{{Name: "a"}}
+
+// Run returns the first default item.
+func Run() string {
+	// Use the defaults.
+	items := defaultItems
+	if len(items) > Limit {
+		return ""
+	}
+	return items[0].String()
+}
+
+func Unused() {}
+`
+
+func TestExtract(t *testing.T) {
+	fset := token.NewFileSet()
+	pkgs := checkPackages(t, fset)
+	f, err := parser.ParseFile(fset, "c.go", extractSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{Importer: importerFunc(func(path string) (*types.Package, error) {
+		return pkgs[path].pkg, nil
+	})}
+	typesPkg, err := conf.Check("example.com/c", fset, []*ast.File{f}, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := &packages.Package{Fset: fset, Syntax: []*ast.File{f}, Types: typesPkg, TypesInfo: info}
+
+	nMap := astcopy.CopyNodeMap{}
+	run := findDecl(f, "Run")
+	cp, err := declcopy.Extract(pkg, run, nMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `package c
+
+import u "example.com/util"
+
+// Limit is the maximum.
+const Limit = 10
+
+// Item is a named item.
+type Item struct {
+	Name string
+}
+
+func (i Item) String() string { return u.Join(i.Name) }
+
+var defaultItems = []Item{{Name: "a"}}
+
+// Run returns the first default item.
+func Run() string {
+	// Use the defaults.
+	items := defaultItems
+	if len(items) > Limit {
+		return ""
+	}
+	return items[0].String()
+}
+`
+	if have := formatNode(t, fset, cp); have != want {
+		t.Errorf("have file:\n%s\nwant:\n%s", have, want)
+	}
+	if nMap[cp.Decls[len(cp.Decls)-1]] != run {
+		t.Error("Run copy is not mapped to its original")
+	}
+	if len(f.Decls) != 7 {
+		t.Errorf("original has %d declarations", len(f.Decls))
+	}
+}
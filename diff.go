@@ -0,0 +1,277 @@
+package astcopy
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+)
+
+// EditKind is a kind of change reported by Diff.
+type EditKind int
+
+const (
+	// NodeReplaced means that a field holds a node
+	// that is not a copy of the original field node.
+	NodeReplaced EditKind = iota
+
+	// FieldChanged means that a non-node field value changed.
+	FieldChanged
+
+	// ElementInserted means that a list got a new element.
+	ElementInserted
+
+	// ElementDeleted means that a list element was removed.
+	ElementDeleted
+
+	// ElementMoved means that a list element changed its relative order.
+	ElementMoved
+)
+
+func (k EditKind) String() string {
+	switch k {
+	case NodeReplaced:
+		return "NodeReplaced"
+	case FieldChanged:
+		return "FieldChanged"
+	case ElementInserted:
+		return "ElementInserted"
+	case ElementDeleted:
+		return "ElementDeleted"
+	case ElementMoved:
+		return "ElementMoved"
+	default:
+		return "EditKind(?)"
+	}
+}
+
+// Edit describes a single change between the original and the edited tree.
+type Edit struct {
+	Kind EditKind
+
+	// Parent is the original node which field was changed.
+	// Parent is nil if the root node itself was replaced.
+	Parent ast.Node
+
+	// Field is the changed field name, like "X" or "List".
+	Field string
+
+	// Index is an element index for list edits, -1 otherwise.
+	// It is an index in the edited list for inserted and moved elements
+	// and an index in the original list for deleted elements.
+	Index int
+
+	// Old is the original node and New is its edited counterpart.
+	// For FieldChanged edits these are nil and OldValue and NewValue
+	// hold the field values.
+	Old, New ast.Node
+
+	OldValue, NewValue interface{}
+}
+
+// Diff returns changes made to the edited tree that was copied from orig.
+//
+// nMap must map edited nodes to their original nodes, as filled by copy
+// functions. Edited nodes missing from nMap are treated as new nodes,
+// unless they are the original nodes themselves.
+// Position fields and Ident.Obj links are not compared.
+//
+// Edits are reported in tree traversal order.
+func Diff(orig, edited ast.Node, nMap CopyNodeMap) []Edit {
+	d := differ{nMap: nMap}
+	orig, edited = orNil(orig), orNil(edited)
+	switch {
+	case orig == nil && edited == nil:
+		return nil
+	case orig == nil || edited == nil || d.origin(edited) != orig:
+		return []Edit{{Kind: NodeReplaced, Index: -1, Old: orig, New: edited}}
+	}
+	d.node(orig, edited)
+	return d.edits
+}
+
+type differ struct {
+	nMap  CopyNodeMap
+	edits []Edit
+}
+
+// origin returns the original node for the edited x node.
+func (d *differ) origin(x ast.Node) ast.Node {
+	if base := d.nMap[x]; base != nil {
+		return base
+	}
+	return x
+}
+
+func (d *differ) add(e Edit) {
+	d.edits = append(d.edits, e)
+}
+
+// node compares fields of x and its edited copy y.
+func (d *differ) node(x, y ast.Node) {
+	xs, ys := nodeFields(x), nodeFields(y)
+	for i := range xs {
+		name, xp, yp := xs[i].name, xs[i].ptr, ys[i].ptr
+		switch {
+		case isNodeField(xp):
+			d.child(x, name, getNode(xp), getNode(yp))
+		case isListField(xp):
+			d.list(x, name, getList(xp), getList(yp))
+		default:
+			switch xp.(type) {
+			case *token.Pos, **ast.Object:
+				continue
+			}
+			xv, yv := scalarValue(xp), scalarValue(yp)
+			if xv != yv {
+				d.add(Edit{
+					Kind:     FieldChanged,
+					Parent:   x,
+					Field:    name,
+					Index:    -1,
+					OldValue: xv,
+					NewValue: yv,
+				})
+			}
+		}
+	}
+
+	if x, ok := x.(*ast.Package); ok {
+		d.files(x, y.(*ast.Package))
+	}
+}
+
+func (d *differ) child(parent ast.Node, name string, x, y ast.Node) {
+	switch {
+	case x == nil && y == nil:
+		return
+	case x == nil || y == nil || d.origin(y) != x:
+		d.add(Edit{
+			Kind:   NodeReplaced,
+			Parent: parent,
+			Field:  name,
+			Index:  -1,
+			Old:    x,
+			New:    y,
+		})
+	default:
+		d.node(x, y)
+	}
+}
+
+func (d *differ) list(parent ast.Node, name string, xs, ys []ast.Node) {
+	index := make(map[ast.Node]int, len(xs))
+	for i, x := range xs {
+		if x != nil {
+			index[x] = i
+		}
+	}
+
+	// matched[j] is an index in xs of the ys[j] origin, or -1.
+	matched := make([]int, len(ys))
+	kept := make([]bool, len(xs))
+	for j, y := range ys {
+		matched[j] = -1
+		if y == nil {
+			continue
+		}
+		if i, ok := index[d.origin(y)]; ok && !kept[i] {
+			matched[j] = i
+			kept[i] = true
+		}
+	}
+
+	for i, x := range xs {
+		if !kept[i] {
+			d.add(Edit{
+				Kind:   ElementDeleted,
+				Parent: parent,
+				Field:  name,
+				Index:  i,
+				Old:    x,
+			})
+		}
+	}
+
+	stable := increasingSubsequence(matched)
+	for j, y := range ys {
+		i := matched[j]
+		switch {
+		case i < 0:
+			d.add(Edit{
+				Kind:   ElementInserted,
+				Parent: parent,
+				Field:  name,
+				Index:  j,
+				New:    y,
+			})
+			continue
+		case !stable[j]:
+			d.add(Edit{
+				Kind:   ElementMoved,
+				Parent: parent,
+				Field:  name,
+				Index:  j,
+				Old:    xs[i],
+				New:    y,
+			})
+		}
+		d.node(xs[i], y)
+	}
+}
+
+func (d *differ) files(x, y *ast.Package) {
+	names := make([]string, 0, len(x.Files)+len(y.Files))
+	for name := range x.Files {
+		names = append(names, name)
+	}
+	for name := range y.Files {
+		if _, ok := x.Files[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f, g := x.Files[name], y.Files[name]
+		switch {
+		case f == nil:
+			d.add(Edit{Kind: ElementInserted, Parent: x, Field: "Files", Index: -1, New: g})
+		case g == nil:
+			d.add(Edit{Kind: ElementDeleted, Parent: x, Field: "Files", Index: -1, Old: f})
+		default:
+			d.child(x, "Files", f, g)
+		}
+	}
+}
+
+// increasingSubsequence returns a mask of the longest increasing subsequence
+// of non-negative xs elements. Negative elements are never included.
+func increasingSubsequence(xs []int) []bool {
+	// tails[k] is an index in xs of the smallest tail
+	// of all increasing subsequences of length k+1.
+	var tails []int
+	prev := make([]int, len(xs))
+	for j, x := range xs {
+		prev[j] = -1
+		if x < 0 {
+			continue
+		}
+		k := sort.Search(len(tails), func(k int) bool { return xs[tails[k]] >= x })
+		if k > 0 {
+			prev[j] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, j)
+		} else {
+			tails[k] = j
+		}
+	}
+
+	mask := make([]bool, len(xs))
+	if len(tails) != 0 {
+		for j := tails[len(tails)-1]; j >= 0; j = prev[j] {
+			mask[j] = true
+		}
+	}
+	return mask
+}
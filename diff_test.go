@@ -0,0 +1,73 @@
+package astcopy_test
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/go-toolsmith/strparse"
+	"github.com/vvakame/astcopy"
+)
+
+func TestDiff(t *testing.T) {
+	orig := strparse.Stmt(`{
+		a()
+		b()
+		c()
+		x = 1 + 2
+	}`).(*ast.BlockStmt)
+
+	nMap := astcopy.CopyNodeMap{}
+	edited := astcopy.BlockStmt(orig, nMap)
+
+	// Delete a(), swap b() and c(), insert d().
+	b, c, assign := edited.List[1], edited.List[2], edited.List[3]
+	d := strparse.Stmt(`d()`)
+	edited.List = []ast.Stmt{c, b, d, assign}
+	// Change 1 + 2 into 1 - f().
+	bin := assign.(*ast.AssignStmt).Rhs[0].(*ast.BinaryExpr)
+	bin.Op = token.SUB
+	bin.Y = strparse.Expr(`f()`)
+
+	var have []string
+	for _, e := range astcopy.Diff(orig, edited, nMap) {
+		s := fmt.Sprintf("%v %T.%s[%d]", e.Kind, e.Parent, e.Field, e.Index)
+		if e.Kind == astcopy.FieldChanged {
+			s += fmt.Sprintf(" %v -> %v", e.OldValue, e.NewValue)
+		}
+		have = append(have, s)
+	}
+	want := []string{
+		"ElementDeleted *ast.BlockStmt.List[0]",
+		"ElementMoved *ast.BlockStmt.List[0]",
+		"ElementInserted *ast.BlockStmt.List[2]",
+		"FieldChanged *ast.BinaryExpr.Op[-1] + -> -",
+		"NodeReplaced *ast.BinaryExpr.Y[-1]",
+	}
+	if strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("edits mismatch:\nhave:\n%s\nwant:\n%s",
+			strings.Join(have, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDiffUnchanged(t *testing.T) {
+	orig := strparse.Decl(`func f(x int) int { return x * 2 }`)
+	nMap := astcopy.CopyNodeMap{}
+	edited := astcopy.Decl(orig, nMap)
+	if edits := astcopy.Diff(orig, edited, nMap); len(edits) != 0 {
+		t.Errorf("unexpected edits: %+v", edits)
+	}
+}
+
+func TestDiffTypedNil(t *testing.T) {
+	// Typed nil children are compared as unset children.
+	var id *ast.Ident
+	orig := &ast.ExprStmt{X: &ast.ParenExpr{X: id}}
+	nMap := astcopy.CopyNodeMap{}
+	edited := astcopy.ExprStmt(orig, nMap)
+	if edits := astcopy.Diff(orig, edited, nMap); len(edits) != 0 {
+		t.Errorf("unexpected edits: %+v", edits)
+	}
+}
@@ -0,0 +1,310 @@
+// Package doccopy implements deep copy operations of go/doc models.
+//
+// The declarations and the examples code the models refer to are copied
+// with astcopy. Nodes referenced more than once in a copied value,
+// like the body a promoted method declaration shares with the embedded
+// type method declaration, stay shared in the copy.
+package doccopy
+
+import (
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"slices"
+
+	"github.com/vvakame/astcopy"
+)
+
+// Package returns x deep copy.
+// Copy of nil argument is nil.
+//
+// The unexported fields used for comment links are shared with x,
+// they are never modified by go/doc.
+func Package(x *doc.Package, nMap astcopy.CopyNodeMap) *doc.Package {
+	c := &copier{}
+	c.pkg(x)
+	c.copyNodes(nMap)
+	return c.pkg(x)
+}
+
+// Type returns x deep copy.
+// Copy of nil argument is nil.
+func Type(x *doc.Type, nMap astcopy.CopyNodeMap) *doc.Type {
+	c := &copier{}
+	c.typ(x)
+	c.copyNodes(nMap)
+	return c.typ(x)
+}
+
+// Func returns x deep copy.
+// Copy of nil argument is nil.
+func Func(x *doc.Func, nMap astcopy.CopyNodeMap) *doc.Func {
+	c := &copier{}
+	c.fn(x)
+	c.copyNodes(nMap)
+	return c.fn(x)
+}
+
+// Value returns x deep copy.
+// Copy of nil argument is nil.
+func Value(x *doc.Value, nMap astcopy.CopyNodeMap) *doc.Value {
+	c := &copier{}
+	c.value(x)
+	c.copyNodes(nMap)
+	return c.value(x)
+}
+
+// Example returns x deep copy.
+// Copy of nil argument is nil.
+func Example(x *doc.Example, nMap astcopy.CopyNodeMap) *doc.Example {
+	c := &copier{}
+	c.example(x)
+	c.copyNodes(nMap)
+	return c.example(x)
+}
+
+// copier copies models in two passes.
+// The first pass collects the nodes the models refer to,
+// copyNodes copies them at once, so nodes shared between models
+// or between declarations stay shared,
+// and the second pass copies the models with the copied nodes.
+type copier struct {
+	// roots are the collected nodes, copies is nil until they are copied.
+	roots  []ast.Node
+	copies map[ast.Node]ast.Node
+}
+
+// node collects x in the first pass and returns x copy in the second one.
+func (c *copier) node(x ast.Node) ast.Node {
+	if x == nil {
+		return nil
+	}
+	if c.copies == nil {
+		c.roots = append(c.roots, x)
+		return nil
+	}
+	return c.copies[x]
+}
+
+// copyNodes copies the collected nodes, wrapped in files of a package
+// that is copied with the shared nodes preserved.
+func (c *copier) copyNodes(nMap astcopy.CopyNodeMap) {
+	c.copies = make(map[ast.Node]ast.Node, len(c.roots))
+	if len(c.roots) == 0 {
+		return
+	}
+
+	pkg := &ast.Package{Files: make(map[string]*ast.File, len(c.roots))}
+	wrappers := map[ast.Node]bool{pkg: true}
+	for i, x := range c.roots {
+		pkg.Files[fmt.Sprintf("%08d", i)] = wrap(x, wrappers)
+	}
+
+	cpMap := astcopy.CopyNodeMap{}
+	copier := astcopy.Copier{NodeMap: cpMap, Sharing: astcopy.PreserveShared}
+	cp := copier.Copy(pkg).(*ast.Package)
+	for i, x := range c.roots {
+		c.copies[x] = unwrap(x, cp.Files[fmt.Sprintf("%08d", i)])
+	}
+	if nMap != nil {
+		for n, orig := range cpMap {
+			if wrappers[orig] {
+				continue
+			}
+			base := nMap[orig]
+			if base == nil {
+				base = orig
+			}
+			nMap[n] = base
+		}
+	}
+}
+
+// wrap returns a file containing x, the created nodes are added to wrappers.
+func wrap(x ast.Node, wrappers map[ast.Node]bool) *ast.File {
+	if f, ok := x.(*ast.File); ok {
+		return f
+	}
+	f := &ast.File{}
+	switch x := x.(type) {
+	case ast.Decl:
+		f.Decls = []ast.Decl{x}
+	case *ast.CommentGroup:
+		f.Comments = []*ast.CommentGroup{x}
+	case *ast.BlockStmt:
+		decl := &ast.FuncDecl{Body: x}
+		f.Decls = []ast.Decl{decl}
+		wrappers[decl] = true
+	default:
+		// Example code is a block or a file, other nodes
+		// are not expected and are wrapped as statements.
+		var stmt ast.Stmt
+		switch x := x.(type) {
+		case ast.Stmt:
+			stmt = x
+		case ast.Expr:
+			stmt = &ast.ExprStmt{X: x}
+			wrappers[stmt] = true
+		default:
+			panic(fmt.Sprintf("doccopy: unexpected %T node", x))
+		}
+		body := &ast.BlockStmt{List: []ast.Stmt{stmt}}
+		decl := &ast.FuncDecl{Body: body}
+		f.Decls = []ast.Decl{decl}
+		wrappers[body] = true
+		wrappers[decl] = true
+	}
+	wrappers[f] = true
+	return f
+}
+
+// unwrap returns the copy of x from f, the copy of x wrap result.
+func unwrap(x ast.Node, f *ast.File) ast.Node {
+	switch x.(type) {
+	case *ast.File:
+		return f
+	case ast.Decl:
+		return f.Decls[0]
+	case *ast.CommentGroup:
+		return f.Comments[0]
+	case *ast.BlockStmt:
+		return f.Decls[0].(*ast.FuncDecl).Body
+	case ast.Stmt:
+		return f.Decls[0].(*ast.FuncDecl).Body.List[0]
+	default:
+		return f.Decls[0].(*ast.FuncDecl).Body.List[0].(*ast.ExprStmt).X
+	}
+}
+
+func (c *copier) pkg(x *doc.Package) *doc.Package {
+	if x == nil {
+		return nil
+	}
+	cp := *x
+	cp.Imports = slices.Clone(x.Imports)
+	cp.Filenames = slices.Clone(x.Filenames)
+	if x.Notes != nil {
+		cp.Notes = make(map[string][]*doc.Note, len(x.Notes))
+		for marker, notes := range x.Notes {
+			cpNotes := make([]*doc.Note, len(notes))
+			for i, note := range notes {
+				if note != nil {
+					cpNote := *note
+					cpNotes[i] = &cpNote
+				}
+			}
+			cp.Notes[marker] = cpNotes
+		}
+	}
+	cp.Bugs = slices.Clone(x.Bugs)
+	cp.Consts = c.values(x.Consts)
+	cp.Types = c.types(x.Types)
+	cp.Vars = c.values(x.Vars)
+	cp.Funcs = c.funcs(x.Funcs)
+	cp.Examples = c.examples(x.Examples)
+	return &cp
+}
+
+func (c *copier) typ(x *doc.Type) *doc.Type {
+	if x == nil {
+		return nil
+	}
+	cp := *x
+	if x.Decl != nil {
+		cp.Decl, _ = c.node(x.Decl).(*ast.GenDecl)
+	}
+	cp.Consts = c.values(x.Consts)
+	cp.Vars = c.values(x.Vars)
+	cp.Funcs = c.funcs(x.Funcs)
+	cp.Methods = c.funcs(x.Methods)
+	cp.Examples = c.examples(x.Examples)
+	return &cp
+}
+
+func (c *copier) types(xs []*doc.Type) []*doc.Type {
+	if xs == nil {
+		return nil
+	}
+	cp := make([]*doc.Type, len(xs))
+	for i := range xs {
+		cp[i] = c.typ(xs[i])
+	}
+	return cp
+}
+
+func (c *copier) fn(x *doc.Func) *doc.Func {
+	if x == nil {
+		return nil
+	}
+	cp := *x
+	if x.Decl != nil {
+		cp.Decl, _ = c.node(x.Decl).(*ast.FuncDecl)
+	}
+	cp.Examples = c.examples(x.Examples)
+	return &cp
+}
+
+func (c *copier) funcs(xs []*doc.Func) []*doc.Func {
+	if xs == nil {
+		return nil
+	}
+	cp := make([]*doc.Func, len(xs))
+	for i := range xs {
+		cp[i] = c.fn(xs[i])
+	}
+	return cp
+}
+
+func (c *copier) value(x *doc.Value) *doc.Value {
+	if x == nil {
+		return nil
+	}
+	cp := *x
+	cp.Names = slices.Clone(x.Names)
+	if x.Decl != nil {
+		cp.Decl, _ = c.node(x.Decl).(*ast.GenDecl)
+	}
+	return &cp
+}
+
+func (c *copier) values(xs []*doc.Value) []*doc.Value {
+	if xs == nil {
+		return nil
+	}
+	cp := make([]*doc.Value, len(xs))
+	for i := range xs {
+		cp[i] = c.value(xs[i])
+	}
+	return cp
+}
+
+func (c *copier) example(x *doc.Example) *doc.Example {
+	if x == nil {
+		return nil
+	}
+	cp := *x
+	cp.Code = c.node(x.Code)
+	if x.Play != nil {
+		cp.Play, _ = c.node(x.Play).(*ast.File)
+	}
+	if x.Comments != nil {
+		cp.Comments = make([]*ast.CommentGroup, len(x.Comments))
+		for i, cg := range x.Comments {
+			if cg != nil {
+				cp.Comments[i], _ = c.node(cg).(*ast.CommentGroup)
+			}
+		}
+	}
+	return &cp
+}
+
+func (c *copier) examples(xs []*doc.Example) []*doc.Example {
+	if xs == nil {
+		return nil
+	}
+	cp := make([]*doc.Example, len(xs))
+	for i := range xs {
+		cp[i] = c.example(xs[i])
+	}
+	return cp
+}
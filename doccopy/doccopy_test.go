@@ -0,0 +1,145 @@
+package doccopy_test
+
+import (
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/vvakame/astcopy"
+	"github.com/vvakame/astcopy/doccopy"
+)
+
+const (
+	docSrc = `// Package p is documented.
+package p
+
+// Limits.
+const (
+	Min = 1
+	Max = 2
+)
+
+// Base has methods.
+type Base struct{}
+
+// Name returns the name.
+func (Base) Name() string { return "base" }
+
+// Derived embeds Base.
+type Derived struct {
+	Base
+}
+
+// NewDerived returns a Derived.
+func NewDerived() *Derived { return &Derived{} }
+
+// BUG(someone): Derived is not tested.
+`
+
+	docTestSrc = `package p_test
+
+import (
+	"fmt"
+
+	"p"
+)
+
+func ExampleDerived_Name() {
+	// Print the name.
+	fmt.Println(p.NewDerived().Name())
+	// Output: base
+}
+`
+)
+
+func TestPackage(t *testing.T) {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for name, src := range map[string]string{"p.go": docSrc, "p_test.go": docTestSrc} {
+		f, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	pkg, err := doc.NewFromFiles(fset, files, "p", doc.AllMethods)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Play files are made by go/doc.
+	origs := make(map[ast.Node]bool)
+	for _, f := range append(files, pkg.Types[1].Methods[0].Examples[0].Play) {
+		ast.Inspect(f, func(n ast.Node) bool {
+			origs[n] = true
+			return true
+		})
+	}
+
+	nMap := astcopy.CopyNodeMap{}
+	cp := doccopy.Package(pkg, nMap)
+
+	if len(cp.Types) != 2 || len(cp.Consts) != 1 || len(cp.Notes["BUG"]) != 1 {
+		t.Fatalf("have %d types, %d consts, %d bugs", len(cp.Types), len(cp.Consts), len(cp.Notes["BUG"]))
+	}
+	base, derived := cp.Types[0], cp.Types[1]
+	if len(base.Methods) != 1 || len(derived.Methods) != 1 || len(derived.Funcs) != 1 {
+		t.Fatalf("have %d Base methods, %d Derived methods and %d funcs",
+			len(base.Methods), len(derived.Methods), len(derived.Funcs))
+	}
+	if pkg.Types[0].Methods[0].Decl.Body != pkg.Types[1].Methods[0].Decl.Body {
+		t.Fatal("original promoted method body is not shared")
+	}
+	if base.Methods[0].Decl.Body != derived.Methods[0].Decl.Body {
+		t.Error("promoted method body is not shared")
+	}
+	if len(derived.Methods[0].Examples) != 1 {
+		t.Fatalf("have %d method examples", len(derived.Methods[0].Examples))
+	}
+	ex := derived.Methods[0].Examples[0]
+	if ex.Output != "base\n" || len(ex.Comments) == 0 {
+		t.Errorf("example output %q with %d comments", ex.Output, len(ex.Comments))
+	}
+
+	checkCopy := func(name string, x, y ast.Node) {
+		t.Helper()
+		if !astcopy.Equal(x, y) {
+			t.Errorf("%s: copy differs", name)
+		}
+		ast.Inspect(y, func(n ast.Node) bool {
+			if n == nil {
+				return false
+			}
+			if origs[n] {
+				t.Fatalf("%s: %T node is not copied", name, n)
+			}
+			if !origs[nMap[n]] {
+				t.Fatalf("%s: %T node is not mapped to its original", name, n)
+			}
+			return true
+		})
+	}
+	checkCopy("Min", pkg.Consts[0].Decl, cp.Consts[0].Decl)
+	checkCopy("Base", pkg.Types[0].Decl, base.Decl)
+	checkCopy("Name", pkg.Types[0].Methods[0].Decl, base.Methods[0].Decl)
+	checkCopy("NewDerived", pkg.Types[1].Funcs[0].Decl, derived.Funcs[0].Decl)
+	origEx := pkg.Types[1].Methods[0].Examples[0]
+	checkCopy("Code", origEx.Code, ex.Code)
+	checkCopy("Play", origEx.Play, ex.Play)
+
+	// Modifying the copy doesn't change the original.
+	cp.Consts[0].Names[0] = "Lower"
+	base.Decl.Specs[0].(*ast.TypeSpec).Name.Name = "Root"
+	cp.Notes["BUG"][0].Body = "fixed"
+	if pkg.Consts[0].Names[0] != "Min" || pkg.Types[0].Decl.Specs[0].(*ast.TypeSpec).Name.Name != "Base" ||
+		pkg.Notes["BUG"][0].Body == "fixed" {
+		t.Error("original is modified")
+	}
+
+	if doccopy.Package(nil, nil) != nil || doccopy.Type(nil, nil) != nil || doccopy.Func(nil, nil) != nil ||
+		doccopy.Value(nil, nil) != nil || doccopy.Example(nil, nil) != nil {
+		t.Error("copy of nil is not nil")
+	}
+}
@@ -0,0 +1,81 @@
+// Package dstcopy converts files between go/ast and github.com/dave/dst trees.
+//
+// Comments of go/ast files become dst decorations and dst decorations
+// become go/ast comments, so no source printing and re-parsing is needed.
+//
+// The conversions use the dst decorator and restorer, which already
+// know where every node type can carry decorations. dstcopy adds
+// the node maps in both directions, reports duplicate dst nodes
+// as errors and fills File.Imports.
+package dstcopy
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+)
+
+// NodeMaps hold mapping between go/ast and dst nodes of a conversion.
+// Comments have no dst node counterparts and are not mapped.
+type NodeMaps struct {
+	// Dst maps go/ast nodes to dst nodes.
+	Dst map[ast.Node]dst.Node
+	// Ast maps dst nodes to go/ast nodes.
+	Ast map[dst.Node]ast.Node
+}
+
+// ToDst returns f converted to dst tree with comments as decorations
+// and the conversion node maps.
+//
+// fset must contain f positions, they are used to place the decorations
+// and the line breaks.
+// Nodes referenced more than once, besides File.Imports elements, stay
+// shared in the result and can't be converted back with ToAst,
+// astcopy.File can be used to unshare them first.
+func ToDst(fset *token.FileSet, f *ast.File) (*dst.File, *NodeMaps, error) {
+	d := decorator.NewDecorator(fset)
+	df, err := d.DecorateFile(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	return df, &NodeMaps{Dst: d.Dst.Nodes, Ast: d.Ast.Nodes}, nil
+}
+
+// ToAst returns f converted to go/ast tree with decorations as comments,
+// a new file set with the synthesized f positions and the conversion
+// node maps.
+//
+// Comments are placed like the dst restorer places them: the result
+// prints the decorations at their places, but the comment groups are not
+// the ones of go/parser and Doc fields are not set. Print and parse
+// the result when go/parser comment grouping is needed.
+// Like with parser.SkipObjectResolution, the result has no objects,
+// scope and unresolved identifiers, File.Imports are filled.
+// ToAst returns an error if a dst node is referenced more than once in f.
+func ToAst(f *dst.File) (fset *token.FileSet, af *ast.File, maps *NodeMaps, err error) {
+	r := decorator.NewRestorer()
+	defer func() {
+		// The restorer panics on duplicate nodes.
+		if p := recover(); p != nil {
+			fset, af, maps = nil, nil, nil
+			err = fmt.Errorf("dstcopy: %v", p)
+		}
+	}()
+	af, err = r.RestoreFile(f)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, decl := range af.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range decl.Specs {
+			af.Imports = append(af.Imports, spec.(*ast.ImportSpec))
+		}
+	}
+	return r.Fset, af, &NodeMaps{Dst: r.Dst.Nodes, Ast: r.Ast.Nodes}, nil
+}
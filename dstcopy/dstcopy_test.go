@@ -0,0 +1,176 @@
+package dstcopy_test
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/dave/dst"
+	"github.com/vvakame/astcopy"
+	"github.com/vvakame/astcopy/dstcopy"
+)
+
+const src = `// Package p is a test package.
+package p
+
+import (
+	"fmt" // for Println
+	"strings"
+)
+
+// T is a type.
+type T[E any] struct {
+	// Name is a name.
+	Name string // trailing
+	list []E
+}
+
+/* Block comment. */
+func (t *T[E]) Print(x int) {
+	if x > 0 { // positive
+		fmt.Println(strings.ToUpper(t.Name))
+	}
+
+	// Free comment.
+	_ = []int{
+		1, // one
+		2,
+	}
+}
+`
+
+func TestRoundTrip(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	df, toDst, err := dstcopy.ToDst(fset, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n.(type) {
+		case nil, *ast.Comment, *ast.CommentGroup:
+			return false
+		}
+		dn := toDst.Dst[n]
+		if dn == nil {
+			t.Errorf("%T node is not mapped to dst", n)
+		} else if toDst.Ast[dn] != n {
+			t.Errorf("%T node maps are inconsistent", n)
+		}
+		return true
+	})
+
+	fset2, f2, toAst, err := dstcopy.ToAst(df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for dn, n := range toAst.Ast {
+		if toAst.Dst[n] != dn {
+			t.Errorf("%T node maps are inconsistent", n)
+		}
+	}
+	// The restorer doesn't group comments like go/parser.
+	if !astcopy.Equal(f, f2, astcopy.IgnorePositions|astcopy.IgnoreComments) {
+		t.Error("round trip changed the tree")
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset2, f2); err != nil {
+		t.Fatal(err)
+	}
+	if have := buf.String(); have != src {
+		t.Errorf("round trip source mismatch:\nhave:\n%s\nwant:\n%s", have, src)
+	}
+}
+
+func TestRoundTripGoroot(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(runtime.GOROOT(), "src", "go", "ast", "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range files {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			t.Fatal(err)
+		}
+		df, _, err := dstcopy.ToDst(fset, f)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		_, f2, _, err := dstcopy.ToAst(df)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !astcopy.Equal(f, f2, astcopy.IgnorePositions|astcopy.IgnoreComments) {
+			t.Errorf("%s: round trip changed the tree", name)
+		}
+	}
+}
+
+func TestToAstEdited(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	df, toDst, err := dstcopy.ToDst(fset, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fn := toDst.Dst[f.Decls[2]].(*dst.FuncDecl)
+	stmt := &dst.ExprStmt{X: &dst.CallExpr{Fun: dst.NewIdent("done")}}
+	stmt.Decs.Before = dst.NewLine
+	stmt.Decs.Start.Append("// Done.")
+	fn.Body.List = append(fn.Body.List, stmt)
+
+	_, f2, toAst, err := dstcopy.ToAst(df)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if toAst.Ast[stmt] == nil {
+		t.Fatal("added statement is not mapped")
+	}
+	body := toAst.Ast[fn].(*ast.FuncDecl).Body
+	if have := body.List[len(body.List)-1]; have != toAst.Ast[stmt] {
+		t.Errorf("have %T last statement, want added one", have)
+	}
+	var found bool
+	for _, cg := range f2.Comments {
+		if cg.Text() == "Done.\n" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("added comment is missing")
+	}
+}
+
+func TestToAstDuplicate(t *testing.T) {
+	id := dst.NewIdent("x")
+	df := &dst.File{
+		Name: dst.NewIdent("p"),
+		Decls: []dst.Decl{
+			&dst.GenDecl{
+				Tok: token.VAR,
+				Specs: []dst.Spec{
+					&dst.ValueSpec{Names: []*dst.Ident{id}, Type: dst.NewIdent("int")},
+					&dst.ValueSpec{Names: []*dst.Ident{id}, Type: dst.NewIdent("int")},
+				},
+			},
+		},
+	}
+	if _, _, _, err := dstcopy.ToAst(df); err == nil {
+		t.Error("duplicate node is not reported")
+	}
+}
@@ -0,0 +1,975 @@
+package astcopy
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// CompareOption controls which parts of the nodes are taken into account
+// by Equal and Hash. Options can be combined with bitwise OR.
+type CompareOption int
+
+const (
+	// IgnorePositions makes all token.Pos fields irrelevant.
+	IgnorePositions CompareOption = 1 << iota
+
+	// IgnoreComments skips Doc and Comment fields, comment nodes
+	// and File.Comments.
+	IgnoreComments
+
+	// IgnoreObjects skips Ident.Obj links, File.Unresolved and Package.Imports.
+	IgnoreObjects
+
+	// IgnoreParens makes (x) and x equal.
+	IgnoreParens
+)
+
+func compareMode(opts []CompareOption) CompareOption {
+	var mode CompareOption
+	for _, opt := range opts {
+		mode |= opt
+	}
+	return mode
+}
+
+// Equal reports whether x and y are structurally equal nodes.
+// Scopes are never compared; Ident.Obj links are compared by kind and name.
+func Equal(x, y ast.Node, opts ...CompareOption) bool {
+	eq := equaler{mode: compareMode(opts)}
+	return eq.node(x, y)
+}
+
+type equaler struct {
+	mode CompareOption
+}
+
+func (eq *equaler) pos(x, y token.Pos) bool {
+	return eq.mode&IgnorePositions != 0 || x == y
+}
+
+func (eq *equaler) obj(x, y *ast.Object) bool {
+	if eq.mode&IgnoreObjects != 0 {
+		return true
+	}
+	if x == nil || y == nil {
+		return x == y
+	}
+	return x.Kind == y.Kind && x.Name == y.Name
+}
+
+func (eq *equaler) node(x, y ast.Node) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+
+	switch x := x.(type) {
+	case ast.Expr:
+		y, ok := y.(ast.Expr)
+		return ok && eq.expr(x, y)
+	case ast.Stmt:
+		y, ok := y.(ast.Stmt)
+		return ok && eq.stmt(x, y)
+	case ast.Decl:
+		y, ok := y.(ast.Decl)
+		return ok && eq.decl(x, y)
+
+	case ast.Spec:
+		y, ok := y.(ast.Spec)
+		return ok && eq.spec(x, y)
+	case *ast.Field:
+		y, ok := y.(*ast.Field)
+		return ok && eq.field(x, y)
+	case *ast.FieldList:
+		y, ok := y.(*ast.FieldList)
+		return ok && eq.fieldList(x, y)
+	case *ast.Comment:
+		y, ok := y.(*ast.Comment)
+		return ok && eq.comment(x, y)
+	case *ast.CommentGroup:
+		y, ok := y.(*ast.CommentGroup)
+		return ok && eq.commentGroup(x, y)
+	case *ast.File:
+		y, ok := y.(*ast.File)
+		return ok && eq.file(x, y)
+	case *ast.Package:
+		y, ok := y.(*ast.Package)
+		return ok && eq.pkg(x, y)
+
+	default:
+		panic("unhandled node")
+	}
+}
+
+func (eq *equaler) exprList(xs, ys []ast.Expr) bool {
+	if len(xs) != len(ys) {
+		return false
+	}
+	for i := range xs {
+		if !eq.expr(xs[i], ys[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (eq *equaler) stmtList(xs, ys []ast.Stmt) bool {
+	if len(xs) != len(ys) {
+		return false
+	}
+	for i := range xs {
+		if !eq.stmt(xs[i], ys[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (eq *equaler) declList(xs, ys []ast.Decl) bool {
+	if len(xs) != len(ys) {
+		return false
+	}
+	for i := range xs {
+		if !eq.decl(xs[i], ys[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (eq *equaler) specList(xs, ys []ast.Spec) bool {
+	if len(xs) != len(ys) {
+		return false
+	}
+	for i := range xs {
+		if !eq.spec(xs[i], ys[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (eq *equaler) identList(xs, ys []*ast.Ident) bool {
+	if len(xs) != len(ys) {
+		return false
+	}
+	for i := range xs {
+		if !eq.ident(xs[i], ys[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (eq *equaler) unparen(x ast.Expr) ast.Expr {
+	if eq.mode&IgnoreParens == 0 {
+		return x
+	}
+	for {
+		p, ok := x.(*ast.ParenExpr)
+		if !ok || p == nil {
+			return x
+		}
+		x = p.X
+	}
+}
+
+func (eq *equaler) expr(x, y ast.Expr) bool {
+	x, y = eq.unparen(x), eq.unparen(y)
+	if x == nil || y == nil {
+		return x == y
+	}
+
+	switch x := x.(type) {
+	case *ast.BadExpr:
+		y, ok := y.(*ast.BadExpr)
+		return ok && eq.badExpr(x, y)
+	case *ast.Ident:
+		y, ok := y.(*ast.Ident)
+		return ok && eq.ident(x, y)
+	case *ast.Ellipsis:
+		y, ok := y.(*ast.Ellipsis)
+		return ok && eq.ellipsis(x, y)
+	case *ast.BasicLit:
+		y, ok := y.(*ast.BasicLit)
+		return ok && eq.basicLit(x, y)
+	case *ast.FuncLit:
+		y, ok := y.(*ast.FuncLit)
+		return ok && eq.funcLit(x, y)
+	case *ast.CompositeLit:
+		y, ok := y.(*ast.CompositeLit)
+		return ok && eq.compositeLit(x, y)
+	case *ast.ParenExpr:
+		y, ok := y.(*ast.ParenExpr)
+		return ok && eq.parenExpr(x, y)
+	case *ast.SelectorExpr:
+		y, ok := y.(*ast.SelectorExpr)
+		return ok && eq.selectorExpr(x, y)
+	case *ast.IndexExpr:
+		y, ok := y.(*ast.IndexExpr)
+		return ok && eq.indexExpr(x, y)
+	case *ast.IndexListExpr:
+		y, ok := y.(*ast.IndexListExpr)
+		return ok && eq.indexListExpr(x, y)
+	case *ast.SliceExpr:
+		y, ok := y.(*ast.SliceExpr)
+		return ok && eq.sliceExpr(x, y)
+	case *ast.TypeAssertExpr:
+		y, ok := y.(*ast.TypeAssertExpr)
+		return ok && eq.typeAssertExpr(x, y)
+	case *ast.CallExpr:
+		y, ok := y.(*ast.CallExpr)
+		return ok && eq.callExpr(x, y)
+	case *ast.StarExpr:
+		y, ok := y.(*ast.StarExpr)
+		return ok && eq.starExpr(x, y)
+	case *ast.UnaryExpr:
+		y, ok := y.(*ast.UnaryExpr)
+		return ok && eq.unaryExpr(x, y)
+	case *ast.BinaryExpr:
+		y, ok := y.(*ast.BinaryExpr)
+		return ok && eq.binaryExpr(x, y)
+	case *ast.KeyValueExpr:
+		y, ok := y.(*ast.KeyValueExpr)
+		return ok && eq.keyValueExpr(x, y)
+	case *ast.ArrayType:
+		y, ok := y.(*ast.ArrayType)
+		return ok && eq.arrayType(x, y)
+	case *ast.StructType:
+		y, ok := y.(*ast.StructType)
+		return ok && eq.structType(x, y)
+	case *ast.FuncType:
+		y, ok := y.(*ast.FuncType)
+		return ok && eq.funcType(x, y)
+	case *ast.InterfaceType:
+		y, ok := y.(*ast.InterfaceType)
+		return ok && eq.interfaceType(x, y)
+	case *ast.MapType:
+		y, ok := y.(*ast.MapType)
+		return ok && eq.mapType(x, y)
+	case *ast.ChanType:
+		y, ok := y.(*ast.ChanType)
+		return ok && eq.chanType(x, y)
+
+	default:
+		panic("unhandled expr")
+	}
+}
+
+func (eq *equaler) badExpr(x, y *ast.BadExpr) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.From, y.From) &&
+		eq.pos(x.To, y.To)
+}
+
+func (eq *equaler) ident(x, y *ast.Ident) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.NamePos, y.NamePos) &&
+		x.Name == y.Name &&
+		eq.obj(x.Obj, y.Obj)
+}
+
+func (eq *equaler) ellipsis(x, y *ast.Ellipsis) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.Ellipsis, y.Ellipsis) &&
+		eq.expr(x.Elt, y.Elt)
+}
+
+func (eq *equaler) basicLit(x, y *ast.BasicLit) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.ValuePos, y.ValuePos) &&
+		eq.pos(*valueEnd(x), *valueEnd(y)) &&
+		x.Kind == y.Kind &&
+		x.Value == y.Value
+}
+
+func (eq *equaler) funcLit(x, y *ast.FuncLit) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.funcType(x.Type, y.Type) &&
+		eq.blockStmt(x.Body, y.Body)
+}
+
+func (eq *equaler) compositeLit(x, y *ast.CompositeLit) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.expr(x.Type, y.Type) &&
+		eq.pos(x.Lbrace, y.Lbrace) &&
+		eq.exprList(x.Elts, y.Elts) &&
+		eq.pos(x.Rbrace, y.Rbrace) &&
+		x.Incomplete == y.Incomplete
+}
+
+func (eq *equaler) parenExpr(x, y *ast.ParenExpr) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.Lparen, y.Lparen) &&
+		eq.expr(x.X, y.X) &&
+		eq.pos(x.Rparen, y.Rparen)
+}
+
+func (eq *equaler) selectorExpr(x, y *ast.SelectorExpr) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.expr(x.X, y.X) &&
+		eq.ident(x.Sel, y.Sel)
+}
+
+func (eq *equaler) indexExpr(x, y *ast.IndexExpr) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.expr(x.X, y.X) &&
+		eq.pos(x.Lbrack, y.Lbrack) &&
+		eq.expr(x.Index, y.Index) &&
+		eq.pos(x.Rbrack, y.Rbrack)
+}
+
+func (eq *equaler) indexListExpr(x, y *ast.IndexListExpr) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.expr(x.X, y.X) &&
+		eq.pos(x.Lbrack, y.Lbrack) &&
+		eq.exprList(x.Indices, y.Indices) &&
+		eq.pos(x.Rbrack, y.Rbrack)
+}
+
+func (eq *equaler) sliceExpr(x, y *ast.SliceExpr) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.expr(x.X, y.X) &&
+		eq.pos(x.Lbrack, y.Lbrack) &&
+		eq.expr(x.Low, y.Low) &&
+		eq.expr(x.High, y.High) &&
+		eq.expr(x.Max, y.Max) &&
+		x.Slice3 == y.Slice3 &&
+		eq.pos(x.Rbrack, y.Rbrack)
+}
+
+func (eq *equaler) typeAssertExpr(x, y *ast.TypeAssertExpr) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.expr(x.X, y.X) &&
+		eq.pos(x.Lparen, y.Lparen) &&
+		eq.expr(x.Type, y.Type) &&
+		eq.pos(x.Rparen, y.Rparen)
+}
+
+func (eq *equaler) callExpr(x, y *ast.CallExpr) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.expr(x.Fun, y.Fun) &&
+		eq.pos(x.Lparen, y.Lparen) &&
+		eq.exprList(x.Args, y.Args) &&
+		// Ellipsis validity is meaningful even if positions are ignored.
+		x.Ellipsis.IsValid() == y.Ellipsis.IsValid() &&
+		eq.pos(x.Ellipsis, y.Ellipsis) &&
+		eq.pos(x.Rparen, y.Rparen)
+}
+
+func (eq *equaler) starExpr(x, y *ast.StarExpr) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.Star, y.Star) &&
+		eq.expr(x.X, y.X)
+}
+
+func (eq *equaler) unaryExpr(x, y *ast.UnaryExpr) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.OpPos, y.OpPos) &&
+		x.Op == y.Op &&
+		eq.expr(x.X, y.X)
+}
+
+func (eq *equaler) binaryExpr(x, y *ast.BinaryExpr) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.expr(x.X, y.X) &&
+		eq.pos(x.OpPos, y.OpPos) &&
+		x.Op == y.Op &&
+		eq.expr(x.Y, y.Y)
+}
+
+func (eq *equaler) keyValueExpr(x, y *ast.KeyValueExpr) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.expr(x.Key, y.Key) &&
+		eq.pos(x.Colon, y.Colon) &&
+		eq.expr(x.Value, y.Value)
+}
+
+func (eq *equaler) arrayType(x, y *ast.ArrayType) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.Lbrack, y.Lbrack) &&
+		eq.expr(x.Len, y.Len) &&
+		eq.expr(x.Elt, y.Elt)
+}
+
+func (eq *equaler) structType(x, y *ast.StructType) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.Struct, y.Struct) &&
+		eq.fieldList(x.Fields, y.Fields) &&
+		x.Incomplete == y.Incomplete
+}
+
+func (eq *equaler) field(x, y *ast.Field) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.docs(x.Doc, y.Doc) &&
+		eq.identList(x.Names, y.Names) &&
+		eq.expr(x.Type, y.Type) &&
+		eq.basicLit(x.Tag, y.Tag) &&
+		eq.docs(x.Comment, y.Comment)
+}
+
+func (eq *equaler) fieldList(x, y *ast.FieldList) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	if len(x.List) != len(y.List) {
+		return false
+	}
+	for i := range x.List {
+		if !eq.field(x.List[i], y.List[i]) {
+			return false
+		}
+	}
+	return eq.pos(x.Opening, y.Opening) &&
+		eq.pos(x.Closing, y.Closing)
+}
+
+func (eq *equaler) funcType(x, y *ast.FuncType) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.Func, y.Func) &&
+		eq.fieldList(x.TypeParams, y.TypeParams) &&
+		eq.fieldList(x.Params, y.Params) &&
+		eq.fieldList(x.Results, y.Results)
+}
+
+func (eq *equaler) interfaceType(x, y *ast.InterfaceType) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.Interface, y.Interface) &&
+		eq.fieldList(x.Methods, y.Methods) &&
+		x.Incomplete == y.Incomplete
+}
+
+func (eq *equaler) mapType(x, y *ast.MapType) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.Map, y.Map) &&
+		eq.expr(x.Key, y.Key) &&
+		eq.expr(x.Value, y.Value)
+}
+
+func (eq *equaler) chanType(x, y *ast.ChanType) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.Begin, y.Begin) &&
+		eq.pos(x.Arrow, y.Arrow) &&
+		x.Dir == y.Dir &&
+		eq.expr(x.Value, y.Value)
+}
+
+func (eq *equaler) stmt(x, y ast.Stmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+
+	switch x := x.(type) {
+	case *ast.BadStmt:
+		y, ok := y.(*ast.BadStmt)
+		return ok && eq.badStmt(x, y)
+	case *ast.DeclStmt:
+		y, ok := y.(*ast.DeclStmt)
+		return ok && eq.declStmt(x, y)
+	case *ast.EmptyStmt:
+		y, ok := y.(*ast.EmptyStmt)
+		return ok && eq.emptyStmt(x, y)
+	case *ast.LabeledStmt:
+		y, ok := y.(*ast.LabeledStmt)
+		return ok && eq.labeledStmt(x, y)
+	case *ast.ExprStmt:
+		y, ok := y.(*ast.ExprStmt)
+		return ok && eq.exprStmt(x, y)
+	case *ast.SendStmt:
+		y, ok := y.(*ast.SendStmt)
+		return ok && eq.sendStmt(x, y)
+	case *ast.IncDecStmt:
+		y, ok := y.(*ast.IncDecStmt)
+		return ok && eq.incDecStmt(x, y)
+	case *ast.AssignStmt:
+		y, ok := y.(*ast.AssignStmt)
+		return ok && eq.assignStmt(x, y)
+	case *ast.GoStmt:
+		y, ok := y.(*ast.GoStmt)
+		return ok && eq.goStmt(x, y)
+	case *ast.DeferStmt:
+		y, ok := y.(*ast.DeferStmt)
+		return ok && eq.deferStmt(x, y)
+	case *ast.ReturnStmt:
+		y, ok := y.(*ast.ReturnStmt)
+		return ok && eq.returnStmt(x, y)
+	case *ast.BranchStmt:
+		y, ok := y.(*ast.BranchStmt)
+		return ok && eq.branchStmt(x, y)
+	case *ast.BlockStmt:
+		y, ok := y.(*ast.BlockStmt)
+		return ok && eq.blockStmt(x, y)
+	case *ast.IfStmt:
+		y, ok := y.(*ast.IfStmt)
+		return ok && eq.ifStmt(x, y)
+	case *ast.CaseClause:
+		y, ok := y.(*ast.CaseClause)
+		return ok && eq.caseClause(x, y)
+	case *ast.SwitchStmt:
+		y, ok := y.(*ast.SwitchStmt)
+		return ok && eq.switchStmt(x, y)
+	case *ast.TypeSwitchStmt:
+		y, ok := y.(*ast.TypeSwitchStmt)
+		return ok && eq.typeSwitchStmt(x, y)
+	case *ast.CommClause:
+		y, ok := y.(*ast.CommClause)
+		return ok && eq.commClause(x, y)
+	case *ast.SelectStmt:
+		y, ok := y.(*ast.SelectStmt)
+		return ok && eq.selectStmt(x, y)
+	case *ast.ForStmt:
+		y, ok := y.(*ast.ForStmt)
+		return ok && eq.forStmt(x, y)
+	case *ast.RangeStmt:
+		y, ok := y.(*ast.RangeStmt)
+		return ok && eq.rangeStmt(x, y)
+
+	default:
+		panic("unhandled stmt")
+	}
+}
+
+func (eq *equaler) badStmt(x, y *ast.BadStmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.From, y.From) &&
+		eq.pos(x.To, y.To)
+}
+
+func (eq *equaler) declStmt(x, y *ast.DeclStmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.decl(x.Decl, y.Decl)
+}
+
+func (eq *equaler) emptyStmt(x, y *ast.EmptyStmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.Semicolon, y.Semicolon) &&
+		x.Implicit == y.Implicit
+}
+
+func (eq *equaler) labeledStmt(x, y *ast.LabeledStmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.ident(x.Label, y.Label) &&
+		eq.pos(x.Colon, y.Colon) &&
+		eq.stmt(x.Stmt, y.Stmt)
+}
+
+func (eq *equaler) exprStmt(x, y *ast.ExprStmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.expr(x.X, y.X)
+}
+
+func (eq *equaler) sendStmt(x, y *ast.SendStmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.expr(x.Chan, y.Chan) &&
+		eq.pos(x.Arrow, y.Arrow) &&
+		eq.expr(x.Value, y.Value)
+}
+
+func (eq *equaler) incDecStmt(x, y *ast.IncDecStmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.expr(x.X, y.X) &&
+		eq.pos(x.TokPos, y.TokPos) &&
+		x.Tok == y.Tok
+}
+
+func (eq *equaler) assignStmt(x, y *ast.AssignStmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.exprList(x.Lhs, y.Lhs) &&
+		eq.pos(x.TokPos, y.TokPos) &&
+		x.Tok == y.Tok &&
+		eq.exprList(x.Rhs, y.Rhs)
+}
+
+func (eq *equaler) goStmt(x, y *ast.GoStmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.Go, y.Go) &&
+		eq.callExpr(x.Call, y.Call)
+}
+
+func (eq *equaler) deferStmt(x, y *ast.DeferStmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.Defer, y.Defer) &&
+		eq.callExpr(x.Call, y.Call)
+}
+
+func (eq *equaler) returnStmt(x, y *ast.ReturnStmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.Return, y.Return) &&
+		eq.exprList(x.Results, y.Results)
+}
+
+func (eq *equaler) branchStmt(x, y *ast.BranchStmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.TokPos, y.TokPos) &&
+		x.Tok == y.Tok &&
+		eq.ident(x.Label, y.Label)
+}
+
+func (eq *equaler) blockStmt(x, y *ast.BlockStmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.Lbrace, y.Lbrace) &&
+		eq.stmtList(x.List, y.List) &&
+		eq.pos(x.Rbrace, y.Rbrace)
+}
+
+func (eq *equaler) ifStmt(x, y *ast.IfStmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.If, y.If) &&
+		eq.stmt(x.Init, y.Init) &&
+		eq.expr(x.Cond, y.Cond) &&
+		eq.blockStmt(x.Body, y.Body) &&
+		eq.stmt(x.Else, y.Else)
+}
+
+func (eq *equaler) caseClause(x, y *ast.CaseClause) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	// Nil List means default case, so nil and empty lists differ.
+	return eq.pos(x.Case, y.Case) &&
+		(x.List == nil) == (y.List == nil) &&
+		eq.exprList(x.List, y.List) &&
+		eq.pos(x.Colon, y.Colon) &&
+		eq.stmtList(x.Body, y.Body)
+}
+
+func (eq *equaler) switchStmt(x, y *ast.SwitchStmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.Switch, y.Switch) &&
+		eq.stmt(x.Init, y.Init) &&
+		eq.expr(x.Tag, y.Tag) &&
+		eq.blockStmt(x.Body, y.Body)
+}
+
+func (eq *equaler) typeSwitchStmt(x, y *ast.TypeSwitchStmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.Switch, y.Switch) &&
+		eq.stmt(x.Init, y.Init) &&
+		eq.stmt(x.Assign, y.Assign) &&
+		eq.blockStmt(x.Body, y.Body)
+}
+
+func (eq *equaler) commClause(x, y *ast.CommClause) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.Case, y.Case) &&
+		eq.stmt(x.Comm, y.Comm) &&
+		eq.pos(x.Colon, y.Colon) &&
+		eq.stmtList(x.Body, y.Body)
+}
+
+func (eq *equaler) selectStmt(x, y *ast.SelectStmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.Select, y.Select) &&
+		eq.blockStmt(x.Body, y.Body)
+}
+
+func (eq *equaler) forStmt(x, y *ast.ForStmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.For, y.For) &&
+		eq.stmt(x.Init, y.Init) &&
+		eq.expr(x.Cond, y.Cond) &&
+		eq.stmt(x.Post, y.Post) &&
+		eq.blockStmt(x.Body, y.Body)
+}
+
+func (eq *equaler) rangeStmt(x, y *ast.RangeStmt) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.For, y.For) &&
+		eq.expr(x.Key, y.Key) &&
+		eq.expr(x.Value, y.Value) &&
+		eq.pos(x.TokPos, y.TokPos) &&
+		x.Tok == y.Tok &&
+		eq.pos(x.Range, y.Range) &&
+		eq.expr(x.X, y.X) &&
+		eq.blockStmt(x.Body, y.Body)
+}
+
+func (eq *equaler) spec(x, y ast.Spec) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+
+	switch x := x.(type) {
+	case *ast.ImportSpec:
+		y, ok := y.(*ast.ImportSpec)
+		return ok && eq.importSpec(x, y)
+	case *ast.ValueSpec:
+		y, ok := y.(*ast.ValueSpec)
+		return ok && eq.valueSpec(x, y)
+	case *ast.TypeSpec:
+		y, ok := y.(*ast.TypeSpec)
+		return ok && eq.typeSpec(x, y)
+
+	default:
+		panic("unhandled spec")
+	}
+}
+
+func (eq *equaler) importSpec(x, y *ast.ImportSpec) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.docs(x.Doc, y.Doc) &&
+		eq.ident(x.Name, y.Name) &&
+		eq.basicLit(x.Path, y.Path) &&
+		eq.docs(x.Comment, y.Comment) &&
+		eq.pos(x.EndPos, y.EndPos)
+}
+
+func (eq *equaler) valueSpec(x, y *ast.ValueSpec) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.docs(x.Doc, y.Doc) &&
+		eq.identList(x.Names, y.Names) &&
+		eq.expr(x.Type, y.Type) &&
+		eq.exprList(x.Values, y.Values) &&
+		eq.docs(x.Comment, y.Comment)
+}
+
+func (eq *equaler) typeSpec(x, y *ast.TypeSpec) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	// Assign validity tells alias declarations apart.
+	return eq.docs(x.Doc, y.Doc) &&
+		eq.ident(x.Name, y.Name) &&
+		eq.fieldList(x.TypeParams, y.TypeParams) &&
+		x.Assign.IsValid() == y.Assign.IsValid() &&
+		eq.pos(x.Assign, y.Assign) &&
+		eq.expr(x.Type, y.Type) &&
+		eq.docs(x.Comment, y.Comment)
+}
+
+func (eq *equaler) decl(x, y ast.Decl) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+
+	switch x := x.(type) {
+	case *ast.BadDecl:
+		y, ok := y.(*ast.BadDecl)
+		return ok && eq.badDecl(x, y)
+	case *ast.GenDecl:
+		y, ok := y.(*ast.GenDecl)
+		return ok && eq.genDecl(x, y)
+	case *ast.FuncDecl:
+		y, ok := y.(*ast.FuncDecl)
+		return ok && eq.funcDecl(x, y)
+
+	default:
+		panic("unhandled decl")
+	}
+}
+
+func (eq *equaler) badDecl(x, y *ast.BadDecl) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.From, y.From) &&
+		eq.pos(x.To, y.To)
+}
+
+func (eq *equaler) genDecl(x, y *ast.GenDecl) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	// Lparen validity tells grouped declarations apart.
+	return eq.docs(x.Doc, y.Doc) &&
+		eq.pos(x.TokPos, y.TokPos) &&
+		x.Tok == y.Tok &&
+		x.Lparen.IsValid() == y.Lparen.IsValid() &&
+		eq.pos(x.Lparen, y.Lparen) &&
+		eq.specList(x.Specs, y.Specs) &&
+		eq.pos(x.Rparen, y.Rparen)
+}
+
+func (eq *equaler) funcDecl(x, y *ast.FuncDecl) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.docs(x.Doc, y.Doc) &&
+		eq.fieldList(x.Recv, y.Recv) &&
+		eq.ident(x.Name, y.Name) &&
+		eq.funcType(x.Type, y.Type) &&
+		eq.blockStmt(x.Body, y.Body)
+}
+
+// docs compares Doc and Comment fields unless comments are ignored.
+func (eq *equaler) docs(x, y *ast.CommentGroup) bool {
+	return eq.mode&IgnoreComments != 0 || eq.commentGroup(x, y)
+}
+
+func (eq *equaler) comment(x, y *ast.Comment) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	return eq.pos(x.Slash, y.Slash) &&
+		x.Text == y.Text
+}
+
+func (eq *equaler) commentGroup(x, y *ast.CommentGroup) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	if len(x.List) != len(y.List) {
+		return false
+	}
+	for i := range x.List {
+		if !eq.comment(x.List[i], y.List[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (eq *equaler) file(x, y *ast.File) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	if !eq.docs(x.Doc, y.Doc) ||
+		!eq.pos(x.Package, y.Package) ||
+		!eq.ident(x.Name, y.Name) ||
+		!eq.declList(x.Decls, y.Decls) ||
+		!eq.pos(x.FileStart, y.FileStart) ||
+		!eq.pos(x.FileEnd, y.FileEnd) ||
+		x.GoVersion != y.GoVersion {
+		return false
+	}
+	if len(x.Imports) != len(y.Imports) {
+		return false
+	}
+	for i := range x.Imports {
+		if !eq.importSpec(x.Imports[i], y.Imports[i]) {
+			return false
+		}
+	}
+	if eq.mode&IgnoreObjects == 0 && !eq.identList(x.Unresolved, y.Unresolved) {
+		return false
+	}
+	if eq.mode&IgnoreComments == 0 {
+		if len(x.Comments) != len(y.Comments) {
+			return false
+		}
+		for i := range x.Comments {
+			if !eq.commentGroup(x.Comments[i], y.Comments[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (eq *equaler) pkg(x, y *ast.Package) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+	if x.Name != y.Name || len(x.Files) != len(y.Files) {
+		return false
+	}
+	for filename, f := range x.Files {
+		g, ok := y.Files[filename]
+		if !ok || !eq.file(f, g) {
+			return false
+		}
+	}
+	if eq.mode&IgnoreObjects == 0 {
+		if len(x.Imports) != len(y.Imports) {
+			return false
+		}
+		for path, obj := range x.Imports {
+			other, ok := y.Imports[path]
+			if !ok || !eq.obj(obj, other) {
+				return false
+			}
+		}
+	}
+	return true
+}
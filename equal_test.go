@@ -0,0 +1,84 @@
+package astcopy_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/go-toolsmith/strparse"
+	"github.com/vvakame/astcopy"
+)
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		x, y string
+		opts []astcopy.CompareOption
+		want bool
+	}{
+		{`1 + 2`, `1 + 2`, nil, true},
+		{`1 + 2`, `1 - 2`, nil, false},
+		{`1 + 2`, `1+2`, nil, false},
+		{`1 + 2`, `1+2`, []astcopy.CompareOption{astcopy.IgnorePositions}, true},
+		{`(a) + b`, `a + b`, []astcopy.CompareOption{astcopy.IgnorePositions}, false},
+		{`(a) + b`, `a + b`, []astcopy.CompareOption{astcopy.IgnorePositions, astcopy.IgnoreParens}, true},
+		{`f(xs...)`, `f(xs)`, []astcopy.CompareOption{astcopy.IgnorePositions}, false},
+		{`[]int{1, 2}`, `[]int{1, 2, 3}`, []astcopy.CompareOption{astcopy.IgnorePositions}, false},
+		{`func(x int) {}`, `func(y int) {}`, []astcopy.CompareOption{astcopy.IgnorePositions}, false},
+	}
+
+	for _, test := range tests {
+		x := strparse.Expr(test.x)
+		y := strparse.Expr(test.y)
+		if have := astcopy.Equal(x, y, test.opts...); have != test.want {
+			t.Errorf("Equal(%q, %q, %v): have %v, want %v",
+				test.x, test.y, test.opts, have, test.want)
+		}
+	}
+}
+
+func TestEqualFile(t *testing.T) {
+	const src = `// Package p is p.
+package p
+
+// Doc comment.
+func f(x int) int {
+	return x // trailing
+}
+`
+	const other = `package p
+
+func f(x int) int {
+	return x
+}
+`
+	fset := token.NewFileSet()
+	x, err := parser.ParseFile(fset, "x.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err := parser.ParseFile(fset, "y.go", other, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !astcopy.Equal(x, astcopy.File(x, nil)) {
+		t.Error("copy is not equal to the original")
+	}
+	if astcopy.Equal(x, y, astcopy.IgnorePositions) {
+		t.Error("files with different comments are equal")
+	}
+	if !astcopy.Equal(x, y, astcopy.IgnorePositions, astcopy.IgnoreComments) {
+		t.Error("files are not equal with ignored positions and comments")
+	}
+
+	// Objects are compared by kind and name.
+	z := astcopy.File(x, nil)
+	z.Decls[0].(*ast.FuncDecl).Name.Obj = ast.NewObj(ast.Var, "f")
+	if astcopy.Equal(x, z) {
+		t.Error("files with different objects are equal")
+	}
+	if !astcopy.Equal(x, z, astcopy.IgnoreObjects) {
+		t.Error("files are not equal with ignored objects")
+	}
+}
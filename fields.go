@@ -0,0 +1,1146 @@
+package astcopy
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// field is a named reference to a node struct field.
+//
+// ptr points to the field itself, so the field can be both read and written.
+// Possible ptr types:
+//
+//	*token.Pos, *token.Token, *string, *bool, *ast.ChanDir, **ast.Object
+//	*ast.Expr, *ast.Stmt, *ast.Decl, **ast.Ident, **ast.BasicLit,
+//	**ast.FuncType, **ast.BlockStmt, **ast.FieldList, **ast.CallExpr,
+//	**ast.CommentGroup
+//	*[]ast.Expr, *[]ast.Stmt, *[]ast.Decl, *[]ast.Spec, *[]*ast.Ident,
+//	*[]*ast.Field, *[]*ast.Comment, *[]*ast.ImportSpec, *[]*ast.CommentGroup
+//
+// Scopes and the maps of ast.Package are not described by fields.
+type field struct {
+	name string
+	ptr  interface{}
+}
+
+// nodeFields returns x fields in declaration order.
+func nodeFields(x ast.Node) []field {
+	switch x := x.(type) {
+	case *ast.BadExpr:
+		return []field{{"From", &x.From}, {"To", &x.To}}
+	case *ast.Ident:
+		return []field{{"NamePos", &x.NamePos}, {"Name", &x.Name}, {"Obj", &x.Obj}}
+	case *ast.Ellipsis:
+		return []field{{"Ellipsis", &x.Ellipsis}, {"Elt", &x.Elt}}
+	case *ast.BasicLit:
+		return []field{{"ValuePos", &x.ValuePos}, {"ValueEnd", valueEnd(x)}, {"Kind", &x.Kind}, {"Value", &x.Value}}
+	case *ast.FuncLit:
+		return []field{{"Type", &x.Type}, {"Body", &x.Body}}
+	case *ast.CompositeLit:
+		return []field{{"Type", &x.Type}, {"Lbrace", &x.Lbrace}, {"Elts", &x.Elts}, {"Rbrace", &x.Rbrace}, {"Incomplete", &x.Incomplete}}
+	case *ast.ParenExpr:
+		return []field{{"Lparen", &x.Lparen}, {"X", &x.X}, {"Rparen", &x.Rparen}}
+	case *ast.SelectorExpr:
+		return []field{{"X", &x.X}, {"Sel", &x.Sel}}
+	case *ast.IndexExpr:
+		return []field{{"X", &x.X}, {"Lbrack", &x.Lbrack}, {"Index", &x.Index}, {"Rbrack", &x.Rbrack}}
+	case *ast.IndexListExpr:
+		return []field{{"X", &x.X}, {"Lbrack", &x.Lbrack}, {"Indices", &x.Indices}, {"Rbrack", &x.Rbrack}}
+	case *ast.SliceExpr:
+		return []field{{"X", &x.X}, {"Lbrack", &x.Lbrack}, {"Low", &x.Low}, {"High", &x.High}, {"Max", &x.Max}, {"Slice3", &x.Slice3}, {"Rbrack", &x.Rbrack}}
+	case *ast.TypeAssertExpr:
+		return []field{{"X", &x.X}, {"Lparen", &x.Lparen}, {"Type", &x.Type}, {"Rparen", &x.Rparen}}
+	case *ast.CallExpr:
+		return []field{{"Fun", &x.Fun}, {"Lparen", &x.Lparen}, {"Args", &x.Args}, {"Ellipsis", &x.Ellipsis}, {"Rparen", &x.Rparen}}
+	case *ast.StarExpr:
+		return []field{{"Star", &x.Star}, {"X", &x.X}}
+	case *ast.UnaryExpr:
+		return []field{{"OpPos", &x.OpPos}, {"Op", &x.Op}, {"X", &x.X}}
+	case *ast.BinaryExpr:
+		return []field{{"X", &x.X}, {"OpPos", &x.OpPos}, {"Op", &x.Op}, {"Y", &x.Y}}
+	case *ast.KeyValueExpr:
+		return []field{{"Key", &x.Key}, {"Colon", &x.Colon}, {"Value", &x.Value}}
+	case *ast.ArrayType:
+		return []field{{"Lbrack", &x.Lbrack}, {"Len", &x.Len}, {"Elt", &x.Elt}}
+	case *ast.StructType:
+		return []field{{"Struct", &x.Struct}, {"Fields", &x.Fields}, {"Incomplete", &x.Incomplete}}
+	case *ast.Field:
+		return []field{{"Doc", &x.Doc}, {"Names", &x.Names}, {"Type", &x.Type}, {"Tag", &x.Tag}, {"Comment", &x.Comment}}
+	case *ast.FieldList:
+		return []field{{"Opening", &x.Opening}, {"List", &x.List}, {"Closing", &x.Closing}}
+	case *ast.FuncType:
+		return []field{{"Func", &x.Func}, {"TypeParams", &x.TypeParams}, {"Params", &x.Params}, {"Results", &x.Results}}
+	case *ast.InterfaceType:
+		return []field{{"Interface", &x.Interface}, {"Methods", &x.Methods}, {"Incomplete", &x.Incomplete}}
+	case *ast.MapType:
+		return []field{{"Map", &x.Map}, {"Key", &x.Key}, {"Value", &x.Value}}
+	case *ast.ChanType:
+		return []field{{"Begin", &x.Begin}, {"Arrow", &x.Arrow}, {"Dir", &x.Dir}, {"Value", &x.Value}}
+
+	case *ast.BadStmt:
+		return []field{{"From", &x.From}, {"To", &x.To}}
+	case *ast.DeclStmt:
+		return []field{{"Decl", &x.Decl}}
+	case *ast.EmptyStmt:
+		return []field{{"Semicolon", &x.Semicolon}, {"Implicit", &x.Implicit}}
+	case *ast.LabeledStmt:
+		return []field{{"Label", &x.Label}, {"Colon", &x.Colon}, {"Stmt", &x.Stmt}}
+	case *ast.ExprStmt:
+		return []field{{"X", &x.X}}
+	case *ast.SendStmt:
+		return []field{{"Chan", &x.Chan}, {"Arrow", &x.Arrow}, {"Value", &x.Value}}
+	case *ast.IncDecStmt:
+		return []field{{"X", &x.X}, {"TokPos", &x.TokPos}, {"Tok", &x.Tok}}
+	case *ast.AssignStmt:
+		return []field{{"Lhs", &x.Lhs}, {"TokPos", &x.TokPos}, {"Tok", &x.Tok}, {"Rhs", &x.Rhs}}
+	case *ast.GoStmt:
+		return []field{{"Go", &x.Go}, {"Call", &x.Call}}
+	case *ast.DeferStmt:
+		return []field{{"Defer", &x.Defer}, {"Call", &x.Call}}
+	case *ast.ReturnStmt:
+		return []field{{"Return", &x.Return}, {"Results", &x.Results}}
+	case *ast.BranchStmt:
+		return []field{{"TokPos", &x.TokPos}, {"Tok", &x.Tok}, {"Label", &x.Label}}
+	case *ast.BlockStmt:
+		return []field{{"Lbrace", &x.Lbrace}, {"List", &x.List}, {"Rbrace", &x.Rbrace}}
+	case *ast.IfStmt:
+		return []field{{"If", &x.If}, {"Init", &x.Init}, {"Cond", &x.Cond}, {"Body", &x.Body}, {"Else", &x.Else}}
+	case *ast.CaseClause:
+		return []field{{"Case", &x.Case}, {"List", &x.List}, {"Colon", &x.Colon}, {"Body", &x.Body}}
+	case *ast.SwitchStmt:
+		return []field{{"Switch", &x.Switch}, {"Init", &x.Init}, {"Tag", &x.Tag}, {"Body", &x.Body}}
+	case *ast.TypeSwitchStmt:
+		return []field{{"Switch", &x.Switch}, {"Init", &x.Init}, {"Assign", &x.Assign}, {"Body", &x.Body}}
+	case *ast.CommClause:
+		return []field{{"Case", &x.Case}, {"Comm", &x.Comm}, {"Colon", &x.Colon}, {"Body", &x.Body}}
+	case *ast.SelectStmt:
+		return []field{{"Select", &x.Select}, {"Body", &x.Body}}
+	case *ast.ForStmt:
+		return []field{{"For", &x.For}, {"Init", &x.Init}, {"Cond", &x.Cond}, {"Post", &x.Post}, {"Body", &x.Body}}
+	case *ast.RangeStmt:
+		return []field{{"For", &x.For}, {"Key", &x.Key}, {"Value", &x.Value}, {"TokPos", &x.TokPos}, {"Tok", &x.Tok}, {"Range", &x.Range}, {"X", &x.X}, {"Body", &x.Body}}
+
+	case *ast.ImportSpec:
+		return []field{{"Doc", &x.Doc}, {"Name", &x.Name}, {"Path", &x.Path}, {"Comment", &x.Comment}, {"EndPos", &x.EndPos}}
+	case *ast.ValueSpec:
+		return []field{{"Doc", &x.Doc}, {"Names", &x.Names}, {"Type", &x.Type}, {"Values", &x.Values}, {"Comment", &x.Comment}}
+	case *ast.TypeSpec:
+		return []field{{"Doc", &x.Doc}, {"Name", &x.Name}, {"TypeParams", &x.TypeParams}, {"Assign", &x.Assign}, {"Type", &x.Type}, {"Comment", &x.Comment}}
+
+	case *ast.BadDecl:
+		return []field{{"From", &x.From}, {"To", &x.To}}
+	case *ast.GenDecl:
+		return []field{{"Doc", &x.Doc}, {"TokPos", &x.TokPos}, {"Tok", &x.Tok}, {"Lparen", &x.Lparen}, {"Specs", &x.Specs}, {"Rparen", &x.Rparen}}
+	case *ast.FuncDecl:
+		return []field{{"Doc", &x.Doc}, {"Recv", &x.Recv}, {"Name", &x.Name}, {"Type", &x.Type}, {"Body", &x.Body}}
+
+	case *ast.Comment:
+		return []field{{"Slash", &x.Slash}, {"Text", &x.Text}}
+	case *ast.CommentGroup:
+		return []field{{"List", &x.List}}
+	case *ast.File:
+		return []field{{"Doc", &x.Doc}, {"Package", &x.Package}, {"Name", &x.Name}, {"Decls", &x.Decls}, {"FileStart", &x.FileStart}, {"FileEnd", &x.FileEnd}, {"Imports", &x.Imports}, {"Unresolved", &x.Unresolved}, {"Comments", &x.Comments}, {"GoVersion", &x.GoVersion}}
+	case *ast.Package:
+		return []field{{"Name", &x.Name}}
+
+	default:
+		panic("unhandled node")
+	}
+}
+
+// isNodeField reports whether ptr refers to a single child node field.
+func isNodeField(ptr interface{}) bool {
+	switch ptr.(type) {
+	case *ast.Expr, *ast.Stmt, *ast.Decl, **ast.Ident, **ast.BasicLit,
+		**ast.FuncType, **ast.BlockStmt, **ast.FieldList, **ast.CallExpr,
+		**ast.CommentGroup:
+		return true
+	default:
+		return false
+	}
+}
+
+// isListField reports whether ptr refers to a node slice field.
+func isListField(ptr interface{}) bool {
+	switch ptr.(type) {
+	case *[]ast.Expr, *[]ast.Stmt, *[]ast.Decl, *[]ast.Spec, *[]*ast.Ident,
+		*[]*ast.Field, *[]*ast.Comment, *[]*ast.ImportSpec, *[]*ast.CommentGroup:
+		return true
+	default:
+		return false
+	}
+}
+
+// getNode returns a node stored in the single child field
+// or in the list element returned by listElem.
+// Typed nil pointers are returned as untyped nil.
+func getNode(ptr interface{}) ast.Node {
+	switch ptr := ptr.(type) {
+	case *ast.Expr:
+		return orNil(*ptr)
+	case *ast.Stmt:
+		return orNil(*ptr)
+	case *ast.Decl:
+		return orNil(*ptr)
+	case **ast.Ident:
+		if *ptr != nil {
+			return *ptr
+		}
+	case **ast.BasicLit:
+		if *ptr != nil {
+			return *ptr
+		}
+	case **ast.FuncType:
+		if *ptr != nil {
+			return *ptr
+		}
+	case **ast.BlockStmt:
+		if *ptr != nil {
+			return *ptr
+		}
+	case **ast.FieldList:
+		if *ptr != nil {
+			return *ptr
+		}
+	case **ast.CallExpr:
+		if *ptr != nil {
+			return *ptr
+		}
+	case **ast.CommentGroup:
+		if *ptr != nil {
+			return *ptr
+		}
+	case *ast.Spec:
+		return orNil(*ptr)
+	case **ast.Field:
+		if *ptr != nil {
+			return *ptr
+		}
+	case **ast.Comment:
+		if *ptr != nil {
+			return *ptr
+		}
+	case **ast.ImportSpec:
+		if *ptr != nil {
+			return *ptr
+		}
+	default:
+		panic("unhandled node field")
+	}
+	return nil
+}
+
+// setNode stores x in the single child field or in the list element
+// returned by listElem.
+// It panics if x type does not match the field type.
+func setNode(ptr interface{}, x ast.Node) {
+	switch ptr := ptr.(type) {
+	case *ast.Expr:
+		*ptr = nil
+		if x != nil {
+			*ptr = x.(ast.Expr)
+		}
+	case *ast.Stmt:
+		*ptr = nil
+		if x != nil {
+			*ptr = x.(ast.Stmt)
+		}
+	case *ast.Decl:
+		*ptr = nil
+		if x != nil {
+			*ptr = x.(ast.Decl)
+		}
+	case **ast.Ident:
+		*ptr = nil
+		if x != nil {
+			*ptr = x.(*ast.Ident)
+		}
+	case **ast.BasicLit:
+		*ptr = nil
+		if x != nil {
+			*ptr = x.(*ast.BasicLit)
+		}
+	case **ast.FuncType:
+		*ptr = nil
+		if x != nil {
+			*ptr = x.(*ast.FuncType)
+		}
+	case **ast.BlockStmt:
+		*ptr = nil
+		if x != nil {
+			*ptr = x.(*ast.BlockStmt)
+		}
+	case **ast.FieldList:
+		*ptr = nil
+		if x != nil {
+			*ptr = x.(*ast.FieldList)
+		}
+	case **ast.CallExpr:
+		*ptr = nil
+		if x != nil {
+			*ptr = x.(*ast.CallExpr)
+		}
+	case **ast.CommentGroup:
+		*ptr = nil
+		if x != nil {
+			*ptr = x.(*ast.CommentGroup)
+		}
+	case *ast.Spec:
+		*ptr = nil
+		if x != nil {
+			*ptr = x.(ast.Spec)
+		}
+	case **ast.Field:
+		*ptr = nil
+		if x != nil {
+			*ptr = x.(*ast.Field)
+		}
+	case **ast.Comment:
+		*ptr = nil
+		if x != nil {
+			*ptr = x.(*ast.Comment)
+		}
+	case **ast.ImportSpec:
+		*ptr = nil
+		if x != nil {
+			*ptr = x.(*ast.ImportSpec)
+		}
+	default:
+		panic("unhandled node field")
+	}
+}
+
+// getList returns nodes stored in the slice field.
+// The result is nil if and only if the slice is nil.
+func getList(ptr interface{}) []ast.Node {
+	var xs []ast.Node
+	add := func(n int, isNil bool, get func(i int) ast.Node) {
+		if isNil {
+			return
+		}
+		xs = make([]ast.Node, n)
+		for i := range xs {
+			xs[i] = get(i)
+		}
+	}
+
+	switch ptr := ptr.(type) {
+	case *[]ast.Expr:
+		s := *ptr
+		add(len(s), s == nil, func(i int) ast.Node { return orNil(s[i]) })
+	case *[]ast.Stmt:
+		s := *ptr
+		add(len(s), s == nil, func(i int) ast.Node { return orNil(s[i]) })
+	case *[]ast.Decl:
+		s := *ptr
+		add(len(s), s == nil, func(i int) ast.Node { return orNil(s[i]) })
+	case *[]ast.Spec:
+		s := *ptr
+		add(len(s), s == nil, func(i int) ast.Node { return orNil(s[i]) })
+	case *[]*ast.Ident:
+		s := *ptr
+		add(len(s), s == nil, func(i int) ast.Node { return getNode(&s[i]) })
+	case *[]*ast.Field:
+		s := *ptr
+		add(len(s), s == nil, func(i int) ast.Node {
+			if s[i] == nil {
+				return nil
+			}
+			return s[i]
+		})
+	case *[]*ast.Comment:
+		s := *ptr
+		add(len(s), s == nil, func(i int) ast.Node {
+			if s[i] == nil {
+				return nil
+			}
+			return s[i]
+		})
+	case *[]*ast.ImportSpec:
+		s := *ptr
+		add(len(s), s == nil, func(i int) ast.Node {
+			if s[i] == nil {
+				return nil
+			}
+			return s[i]
+		})
+	case *[]*ast.CommentGroup:
+		s := *ptr
+		add(len(s), s == nil, func(i int) ast.Node { return getNode(&s[i]) })
+	default:
+		panic("unhandled list field")
+	}
+	return xs
+}
+
+// setList stores xs in the slice field.
+// A nil xs results in a nil slice.
+// It panics if element types do not match the field type.
+func setList(ptr interface{}, xs []ast.Node) {
+	isNil := xs == nil
+	switch ptr := ptr.(type) {
+	case *[]ast.Expr:
+		*ptr = nil
+		if !isNil {
+			*ptr = make([]ast.Expr, len(xs))
+			for i := range xs {
+				setNode(&(*ptr)[i], xs[i])
+			}
+		}
+	case *[]ast.Stmt:
+		*ptr = nil
+		if !isNil {
+			*ptr = make([]ast.Stmt, len(xs))
+			for i := range xs {
+				setNode(&(*ptr)[i], xs[i])
+			}
+		}
+	case *[]ast.Decl:
+		*ptr = nil
+		if !isNil {
+			*ptr = make([]ast.Decl, len(xs))
+			for i := range xs {
+				setNode(&(*ptr)[i], xs[i])
+			}
+		}
+	case *[]ast.Spec:
+		*ptr = nil
+		if !isNil {
+			*ptr = make([]ast.Spec, len(xs))
+			for i, x := range xs {
+				if x != nil {
+					(*ptr)[i] = x.(ast.Spec)
+				}
+			}
+		}
+	case *[]*ast.Ident:
+		*ptr = nil
+		if !isNil {
+			*ptr = make([]*ast.Ident, len(xs))
+			for i := range xs {
+				setNode(&(*ptr)[i], xs[i])
+			}
+		}
+	case *[]*ast.Field:
+		*ptr = nil
+		if !isNil {
+			*ptr = make([]*ast.Field, len(xs))
+			for i, x := range xs {
+				if x != nil {
+					(*ptr)[i] = x.(*ast.Field)
+				}
+			}
+		}
+	case *[]*ast.Comment:
+		*ptr = nil
+		if !isNil {
+			*ptr = make([]*ast.Comment, len(xs))
+			for i, x := range xs {
+				if x != nil {
+					(*ptr)[i] = x.(*ast.Comment)
+				}
+			}
+		}
+	case *[]*ast.ImportSpec:
+		*ptr = nil
+		if !isNil {
+			*ptr = make([]*ast.ImportSpec, len(xs))
+			for i, x := range xs {
+				if x != nil {
+					(*ptr)[i] = x.(*ast.ImportSpec)
+				}
+			}
+		}
+	case *[]*ast.CommentGroup:
+		*ptr = nil
+		if !isNil {
+			*ptr = make([]*ast.CommentGroup, len(xs))
+			for i := range xs {
+				setNode(&(*ptr)[i], xs[i])
+			}
+		}
+	default:
+		panic("unhandled list field")
+	}
+}
+
+// orNil returns x, or untyped nil if x is a typed nil pointer.
+func orNil(x ast.Node) ast.Node {
+	var isNil bool
+	switch x := x.(type) {
+	case *ast.BadExpr:
+		isNil = x == nil
+	case *ast.Ident:
+		isNil = x == nil
+	case *ast.Ellipsis:
+		isNil = x == nil
+	case *ast.BasicLit:
+		isNil = x == nil
+	case *ast.FuncLit:
+		isNil = x == nil
+	case *ast.CompositeLit:
+		isNil = x == nil
+	case *ast.ParenExpr:
+		isNil = x == nil
+	case *ast.SelectorExpr:
+		isNil = x == nil
+	case *ast.IndexExpr:
+		isNil = x == nil
+	case *ast.IndexListExpr:
+		isNil = x == nil
+	case *ast.SliceExpr:
+		isNil = x == nil
+	case *ast.TypeAssertExpr:
+		isNil = x == nil
+	case *ast.CallExpr:
+		isNil = x == nil
+	case *ast.StarExpr:
+		isNil = x == nil
+	case *ast.UnaryExpr:
+		isNil = x == nil
+	case *ast.BinaryExpr:
+		isNil = x == nil
+	case *ast.KeyValueExpr:
+		isNil = x == nil
+	case *ast.ArrayType:
+		isNil = x == nil
+	case *ast.StructType:
+		isNil = x == nil
+	case *ast.Field:
+		isNil = x == nil
+	case *ast.FieldList:
+		isNil = x == nil
+	case *ast.FuncType:
+		isNil = x == nil
+	case *ast.InterfaceType:
+		isNil = x == nil
+	case *ast.MapType:
+		isNil = x == nil
+	case *ast.ChanType:
+		isNil = x == nil
+	case *ast.BadStmt:
+		isNil = x == nil
+	case *ast.DeclStmt:
+		isNil = x == nil
+	case *ast.EmptyStmt:
+		isNil = x == nil
+	case *ast.LabeledStmt:
+		isNil = x == nil
+	case *ast.ExprStmt:
+		isNil = x == nil
+	case *ast.SendStmt:
+		isNil = x == nil
+	case *ast.IncDecStmt:
+		isNil = x == nil
+	case *ast.AssignStmt:
+		isNil = x == nil
+	case *ast.GoStmt:
+		isNil = x == nil
+	case *ast.DeferStmt:
+		isNil = x == nil
+	case *ast.ReturnStmt:
+		isNil = x == nil
+	case *ast.BranchStmt:
+		isNil = x == nil
+	case *ast.BlockStmt:
+		isNil = x == nil
+	case *ast.IfStmt:
+		isNil = x == nil
+	case *ast.CaseClause:
+		isNil = x == nil
+	case *ast.SwitchStmt:
+		isNil = x == nil
+	case *ast.TypeSwitchStmt:
+		isNil = x == nil
+	case *ast.CommClause:
+		isNil = x == nil
+	case *ast.SelectStmt:
+		isNil = x == nil
+	case *ast.ForStmt:
+		isNil = x == nil
+	case *ast.RangeStmt:
+		isNil = x == nil
+	case *ast.ImportSpec:
+		isNil = x == nil
+	case *ast.ValueSpec:
+		isNil = x == nil
+	case *ast.TypeSpec:
+		isNil = x == nil
+	case *ast.BadDecl:
+		isNil = x == nil
+	case *ast.GenDecl:
+		isNil = x == nil
+	case *ast.FuncDecl:
+		isNil = x == nil
+	case *ast.Comment:
+		isNil = x == nil
+	case *ast.CommentGroup:
+		isNil = x == nil
+	case *ast.File:
+		isNil = x == nil
+	case *ast.Package:
+		isNil = x == nil
+	}
+	if isNil {
+		return nil
+	}
+	return x
+}
+
+// scalarValue returns a value stored in the non-node field.
+func scalarValue(ptr interface{}) interface{} {
+	switch ptr := ptr.(type) {
+	case *token.Pos:
+		return *ptr
+	case *token.Token:
+		return *ptr
+	case *string:
+		return *ptr
+	case *bool:
+		return *ptr
+	case *ast.ChanDir:
+		return *ptr
+	case **ast.Object:
+		return *ptr
+	default:
+		panic("unhandled scalar field")
+	}
+}
+
+// nodeName returns x type name without the package qualifier, like "Ident".
+func nodeName(x ast.Node) string {
+	switch x.(type) {
+	case *ast.BadExpr:
+		return "BadExpr"
+	case *ast.Ident:
+		return "Ident"
+	case *ast.Ellipsis:
+		return "Ellipsis"
+	case *ast.BasicLit:
+		return "BasicLit"
+	case *ast.FuncLit:
+		return "FuncLit"
+	case *ast.CompositeLit:
+		return "CompositeLit"
+	case *ast.ParenExpr:
+		return "ParenExpr"
+	case *ast.SelectorExpr:
+		return "SelectorExpr"
+	case *ast.IndexExpr:
+		return "IndexExpr"
+	case *ast.IndexListExpr:
+		return "IndexListExpr"
+	case *ast.SliceExpr:
+		return "SliceExpr"
+	case *ast.TypeAssertExpr:
+		return "TypeAssertExpr"
+	case *ast.CallExpr:
+		return "CallExpr"
+	case *ast.StarExpr:
+		return "StarExpr"
+	case *ast.UnaryExpr:
+		return "UnaryExpr"
+	case *ast.BinaryExpr:
+		return "BinaryExpr"
+	case *ast.KeyValueExpr:
+		return "KeyValueExpr"
+	case *ast.ArrayType:
+		return "ArrayType"
+	case *ast.StructType:
+		return "StructType"
+	case *ast.Field:
+		return "Field"
+	case *ast.FieldList:
+		return "FieldList"
+	case *ast.FuncType:
+		return "FuncType"
+	case *ast.InterfaceType:
+		return "InterfaceType"
+	case *ast.MapType:
+		return "MapType"
+	case *ast.ChanType:
+		return "ChanType"
+	case *ast.BadStmt:
+		return "BadStmt"
+	case *ast.DeclStmt:
+		return "DeclStmt"
+	case *ast.EmptyStmt:
+		return "EmptyStmt"
+	case *ast.LabeledStmt:
+		return "LabeledStmt"
+	case *ast.ExprStmt:
+		return "ExprStmt"
+	case *ast.SendStmt:
+		return "SendStmt"
+	case *ast.IncDecStmt:
+		return "IncDecStmt"
+	case *ast.AssignStmt:
+		return "AssignStmt"
+	case *ast.GoStmt:
+		return "GoStmt"
+	case *ast.DeferStmt:
+		return "DeferStmt"
+	case *ast.ReturnStmt:
+		return "ReturnStmt"
+	case *ast.BranchStmt:
+		return "BranchStmt"
+	case *ast.BlockStmt:
+		return "BlockStmt"
+	case *ast.IfStmt:
+		return "IfStmt"
+	case *ast.CaseClause:
+		return "CaseClause"
+	case *ast.SwitchStmt:
+		return "SwitchStmt"
+	case *ast.TypeSwitchStmt:
+		return "TypeSwitchStmt"
+	case *ast.CommClause:
+		return "CommClause"
+	case *ast.SelectStmt:
+		return "SelectStmt"
+	case *ast.ForStmt:
+		return "ForStmt"
+	case *ast.RangeStmt:
+		return "RangeStmt"
+	case *ast.ImportSpec:
+		return "ImportSpec"
+	case *ast.ValueSpec:
+		return "ValueSpec"
+	case *ast.TypeSpec:
+		return "TypeSpec"
+	case *ast.BadDecl:
+		return "BadDecl"
+	case *ast.GenDecl:
+		return "GenDecl"
+	case *ast.FuncDecl:
+		return "FuncDecl"
+	case *ast.Comment:
+		return "Comment"
+	case *ast.CommentGroup:
+		return "CommentGroup"
+	case *ast.File:
+		return "File"
+	case *ast.Package:
+		return "Package"
+	default:
+		panic("unhandled node")
+	}
+}
+
+// shallowCopy returns x copy that shares x fields values.
+func shallowCopy(x ast.Node) ast.Node {
+	switch x := x.(type) {
+	case *ast.BadExpr:
+		cp := *x
+		return &cp
+	case *ast.Ident:
+		cp := *x
+		return &cp
+	case *ast.Ellipsis:
+		cp := *x
+		return &cp
+	case *ast.BasicLit:
+		cp := *x
+		return &cp
+	case *ast.FuncLit:
+		cp := *x
+		return &cp
+	case *ast.CompositeLit:
+		cp := *x
+		return &cp
+	case *ast.ParenExpr:
+		cp := *x
+		return &cp
+	case *ast.SelectorExpr:
+		cp := *x
+		return &cp
+	case *ast.IndexExpr:
+		cp := *x
+		return &cp
+	case *ast.IndexListExpr:
+		cp := *x
+		return &cp
+	case *ast.SliceExpr:
+		cp := *x
+		return &cp
+	case *ast.TypeAssertExpr:
+		cp := *x
+		return &cp
+	case *ast.CallExpr:
+		cp := *x
+		return &cp
+	case *ast.StarExpr:
+		cp := *x
+		return &cp
+	case *ast.UnaryExpr:
+		cp := *x
+		return &cp
+	case *ast.BinaryExpr:
+		cp := *x
+		return &cp
+	case *ast.KeyValueExpr:
+		cp := *x
+		return &cp
+	case *ast.ArrayType:
+		cp := *x
+		return &cp
+	case *ast.StructType:
+		cp := *x
+		return &cp
+	case *ast.Field:
+		cp := *x
+		return &cp
+	case *ast.FieldList:
+		cp := *x
+		return &cp
+	case *ast.FuncType:
+		cp := *x
+		return &cp
+	case *ast.InterfaceType:
+		cp := *x
+		return &cp
+	case *ast.MapType:
+		cp := *x
+		return &cp
+	case *ast.ChanType:
+		cp := *x
+		return &cp
+	case *ast.BadStmt:
+		cp := *x
+		return &cp
+	case *ast.DeclStmt:
+		cp := *x
+		return &cp
+	case *ast.EmptyStmt:
+		cp := *x
+		return &cp
+	case *ast.LabeledStmt:
+		cp := *x
+		return &cp
+	case *ast.ExprStmt:
+		cp := *x
+		return &cp
+	case *ast.SendStmt:
+		cp := *x
+		return &cp
+	case *ast.IncDecStmt:
+		cp := *x
+		return &cp
+	case *ast.AssignStmt:
+		cp := *x
+		return &cp
+	case *ast.GoStmt:
+		cp := *x
+		return &cp
+	case *ast.DeferStmt:
+		cp := *x
+		return &cp
+	case *ast.ReturnStmt:
+		cp := *x
+		return &cp
+	case *ast.BranchStmt:
+		cp := *x
+		return &cp
+	case *ast.BlockStmt:
+		cp := *x
+		return &cp
+	case *ast.IfStmt:
+		cp := *x
+		return &cp
+	case *ast.CaseClause:
+		cp := *x
+		return &cp
+	case *ast.SwitchStmt:
+		cp := *x
+		return &cp
+	case *ast.TypeSwitchStmt:
+		cp := *x
+		return &cp
+	case *ast.CommClause:
+		cp := *x
+		return &cp
+	case *ast.SelectStmt:
+		cp := *x
+		return &cp
+	case *ast.ForStmt:
+		cp := *x
+		return &cp
+	case *ast.RangeStmt:
+		cp := *x
+		return &cp
+	case *ast.ImportSpec:
+		cp := *x
+		return &cp
+	case *ast.ValueSpec:
+		cp := *x
+		return &cp
+	case *ast.TypeSpec:
+		cp := *x
+		return &cp
+	case *ast.BadDecl:
+		cp := *x
+		return &cp
+	case *ast.GenDecl:
+		cp := *x
+		return &cp
+	case *ast.FuncDecl:
+		cp := *x
+		return &cp
+	case *ast.Comment:
+		cp := *x
+		return &cp
+	case *ast.CommentGroup:
+		cp := *x
+		return &cp
+	case *ast.File:
+		cp := *x
+		return &cp
+	case *ast.Package:
+		cp := *x
+		return &cp
+	default:
+		panic("unhandled node")
+	}
+}
+
+// listElem returns a pointer to the i-th element of the slice field.
+// The result can be used with getNode and setNode.
+func listElem(ptr interface{}, i int) interface{} {
+	switch ptr := ptr.(type) {
+	case *[]ast.Expr:
+		return &(*ptr)[i]
+	case *[]ast.Stmt:
+		return &(*ptr)[i]
+	case *[]ast.Decl:
+		return &(*ptr)[i]
+	case *[]ast.Spec:
+		return &(*ptr)[i]
+	case *[]*ast.Ident:
+		return &(*ptr)[i]
+	case *[]*ast.Field:
+		return &(*ptr)[i]
+	case *[]*ast.Comment:
+		return &(*ptr)[i]
+	case *[]*ast.ImportSpec:
+		return &(*ptr)[i]
+	case *[]*ast.CommentGroup:
+		return &(*ptr)[i]
+	default:
+		panic("unhandled list field")
+	}
+}
+
+// cloneList replaces the slice field value with its copy
+// and returns the slice length.
+func cloneList(ptr interface{}) int {
+	switch ptr := ptr.(type) {
+	case *[]ast.Expr:
+		if *ptr != nil {
+			*ptr = append([]ast.Expr{}, *ptr...)
+		}
+		return len(*ptr)
+	case *[]ast.Stmt:
+		if *ptr != nil {
+			*ptr = append([]ast.Stmt{}, *ptr...)
+		}
+		return len(*ptr)
+	case *[]ast.Decl:
+		if *ptr != nil {
+			*ptr = append([]ast.Decl{}, *ptr...)
+		}
+		return len(*ptr)
+	case *[]ast.Spec:
+		if *ptr != nil {
+			*ptr = append([]ast.Spec{}, *ptr...)
+		}
+		return len(*ptr)
+	case *[]*ast.Ident:
+		if *ptr != nil {
+			*ptr = append([]*ast.Ident{}, *ptr...)
+		}
+		return len(*ptr)
+	case *[]*ast.Field:
+		if *ptr != nil {
+			*ptr = append([]*ast.Field{}, *ptr...)
+		}
+		return len(*ptr)
+	case *[]*ast.Comment:
+		if *ptr != nil {
+			*ptr = append([]*ast.Comment{}, *ptr...)
+		}
+		return len(*ptr)
+	case *[]*ast.ImportSpec:
+		if *ptr != nil {
+			*ptr = append([]*ast.ImportSpec{}, *ptr...)
+		}
+		return len(*ptr)
+	case *[]*ast.CommentGroup:
+		if *ptr != nil {
+			*ptr = append([]*ast.CommentGroup{}, *ptr...)
+		}
+		return len(*ptr)
+	default:
+		panic("unhandled list field")
+	}
+}
+
+// newNode returns a new zero node of the named type,
+// or nil if there is no such node type.
+func newNode(name string) ast.Node {
+	switch name {
+	case "BadExpr":
+		return &ast.BadExpr{}
+	case "Ident":
+		return &ast.Ident{}
+	case "Ellipsis":
+		return &ast.Ellipsis{}
+	case "BasicLit":
+		return &ast.BasicLit{}
+	case "FuncLit":
+		return &ast.FuncLit{}
+	case "CompositeLit":
+		return &ast.CompositeLit{}
+	case "ParenExpr":
+		return &ast.ParenExpr{}
+	case "SelectorExpr":
+		return &ast.SelectorExpr{}
+	case "IndexExpr":
+		return &ast.IndexExpr{}
+	case "IndexListExpr":
+		return &ast.IndexListExpr{}
+	case "SliceExpr":
+		return &ast.SliceExpr{}
+	case "TypeAssertExpr":
+		return &ast.TypeAssertExpr{}
+	case "CallExpr":
+		return &ast.CallExpr{}
+	case "StarExpr":
+		return &ast.StarExpr{}
+	case "UnaryExpr":
+		return &ast.UnaryExpr{}
+	case "BinaryExpr":
+		return &ast.BinaryExpr{}
+	case "KeyValueExpr":
+		return &ast.KeyValueExpr{}
+	case "ArrayType":
+		return &ast.ArrayType{}
+	case "StructType":
+		return &ast.StructType{}
+	case "Field":
+		return &ast.Field{}
+	case "FieldList":
+		return &ast.FieldList{}
+	case "FuncType":
+		return &ast.FuncType{}
+	case "InterfaceType":
+		return &ast.InterfaceType{}
+	case "MapType":
+		return &ast.MapType{}
+	case "ChanType":
+		return &ast.ChanType{}
+	case "BadStmt":
+		return &ast.BadStmt{}
+	case "DeclStmt":
+		return &ast.DeclStmt{}
+	case "EmptyStmt":
+		return &ast.EmptyStmt{}
+	case "LabeledStmt":
+		return &ast.LabeledStmt{}
+	case "ExprStmt":
+		return &ast.ExprStmt{}
+	case "SendStmt":
+		return &ast.SendStmt{}
+	case "IncDecStmt":
+		return &ast.IncDecStmt{}
+	case "AssignStmt":
+		return &ast.AssignStmt{}
+	case "GoStmt":
+		return &ast.GoStmt{}
+	case "DeferStmt":
+		return &ast.DeferStmt{}
+	case "ReturnStmt":
+		return &ast.ReturnStmt{}
+	case "BranchStmt":
+		return &ast.BranchStmt{}
+	case "BlockStmt":
+		return &ast.BlockStmt{}
+	case "IfStmt":
+		return &ast.IfStmt{}
+	case "CaseClause":
+		return &ast.CaseClause{}
+	case "SwitchStmt":
+		return &ast.SwitchStmt{}
+	case "TypeSwitchStmt":
+		return &ast.TypeSwitchStmt{}
+	case "CommClause":
+		return &ast.CommClause{}
+	case "SelectStmt":
+		return &ast.SelectStmt{}
+	case "ForStmt":
+		return &ast.ForStmt{}
+	case "RangeStmt":
+		return &ast.RangeStmt{}
+	case "ImportSpec":
+		return &ast.ImportSpec{}
+	case "ValueSpec":
+		return &ast.ValueSpec{}
+	case "TypeSpec":
+		return &ast.TypeSpec{}
+	case "BadDecl":
+		return &ast.BadDecl{}
+	case "GenDecl":
+		return &ast.GenDecl{}
+	case "FuncDecl":
+		return &ast.FuncDecl{}
+	case "Comment":
+		return &ast.Comment{}
+	case "CommentGroup":
+		return &ast.CommentGroup{}
+	case "File":
+		return &ast.File{}
+	case "Package":
+		return &ast.Package{}
+	default:
+		return nil
+	}
+}
+
+// fits reports whether x can be stored in the single child field
+// or as an element of the slice field.
+func fits(ptr interface{}, x ast.Node) bool {
+	if x == nil {
+		return true
+	}
+	var ok bool
+	switch ptr.(type) {
+	case *ast.Expr, *[]ast.Expr:
+		_, ok = x.(ast.Expr)
+	case *ast.Stmt, *[]ast.Stmt:
+		_, ok = x.(ast.Stmt)
+	case *ast.Decl, *[]ast.Decl:
+		_, ok = x.(ast.Decl)
+	case *[]ast.Spec:
+		_, ok = x.(ast.Spec)
+	case **ast.Ident, *[]*ast.Ident:
+		_, ok = x.(*ast.Ident)
+	case **ast.BasicLit:
+		_, ok = x.(*ast.BasicLit)
+	case **ast.FuncType:
+		_, ok = x.(*ast.FuncType)
+	case **ast.BlockStmt:
+		_, ok = x.(*ast.BlockStmt)
+	case **ast.FieldList:
+		_, ok = x.(*ast.FieldList)
+	case **ast.CallExpr:
+		_, ok = x.(*ast.CallExpr)
+	case **ast.CommentGroup, *[]*ast.CommentGroup:
+		_, ok = x.(*ast.CommentGroup)
+	case *[]*ast.Field:
+		_, ok = x.(*ast.Field)
+	case *[]*ast.Comment:
+		_, ok = x.(*ast.Comment)
+	case *[]*ast.ImportSpec:
+		_, ok = x.(*ast.ImportSpec)
+	}
+	return ok
+}
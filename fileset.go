@@ -0,0 +1,99 @@
+package astcopy
+
+import (
+	"go/ast"
+	"go/token"
+	"sort"
+)
+
+// FileSet returns fset deep copy.
+// The copy contains equivalent files with the same names, bases, sizes,
+// line tables and line directives, so positions of fset are valid in it.
+func FileSet(fset *token.FileSet) *token.FileSet {
+	if fset == nil {
+		return nil
+	}
+	var files []*token.File
+	fset.Iterate(func(f *token.File) bool {
+		files = append(files, f)
+		return true
+	})
+	return copyFiles(files)
+}
+
+// Snapshot returns files deep copies together with a new file set
+// that contains only copies of the fset files the copies refer to.
+// The file set does not share any state with fset. Like with File,
+// the copies still share Ident.Obj objects and File.Scope with files.
+//
+// Positions are kept as is, like with FileSet.
+func Snapshot(fset *token.FileSet, files []*ast.File, nMap CopyNodeMap) (*token.FileSet, []*ast.File) {
+	seen := make(map[*token.File]bool)
+	var tokFiles []*token.File
+	cps := make([]*ast.File, len(files))
+	for i, f := range files {
+		cps[i] = File(f, nMap)
+		if f == nil {
+			continue
+		}
+		pos := f.FileStart
+		if !pos.IsValid() {
+			pos = f.Pos()
+		}
+		tf := fset.File(pos)
+		if tf != nil && !seen[tf] {
+			seen[tf] = true
+			tokFiles = append(tokFiles, tf)
+		}
+	}
+	return copyFiles(tokFiles), cps
+}
+
+func copyFiles(files []*token.File) *token.FileSet {
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Base() < files[j].Base()
+	})
+	fset := token.NewFileSet()
+	for _, f := range files {
+		cp := fset.AddFile(f.Name(), f.Base(), f.Size())
+		cp.SetLines(f.Lines())
+		copyLineInfos(cp, f)
+	}
+	return fset
+}
+
+// copyLineInfos adds alternative positions of line directives in src to dst.
+//
+// token.File doesn't expose its line infos, so they are recovered where
+// directives take effect: //line directives at a line start and /*line*/
+// ones right after the comment. Line starts are checked first, and only
+// the lines whose last position still differs are scanned, so the cost
+// grows with the line count rather than the file size.
+// The result is equivalent to src line infos except redundant ones.
+func copyLineInfos(dst, src *token.File) {
+	lines := src.Lines()
+	for i, start := range lines {
+		last := src.Size()
+		if i+1 < len(lines) {
+			last = lines[i+1] - 1
+		}
+		addLineInfo(dst, src, start)
+		if last <= start || samePosition(dst, src, last) {
+			continue
+		}
+		for offset := start + 1; offset <= last; offset++ {
+			addLineInfo(dst, src, offset)
+		}
+	}
+}
+
+func addLineInfo(dst, src *token.File, offset int) {
+	if !samePosition(dst, src, offset) {
+		pos := src.PositionFor(src.Pos(offset), true)
+		dst.AddLineColumnInfo(offset, pos.Filename, pos.Line, pos.Column)
+	}
+}
+
+func samePosition(dst, src *token.File, offset int) bool {
+	return src.PositionFor(src.Pos(offset), true) == dst.PositionFor(dst.Pos(offset), true)
+}
@@ -0,0 +1,119 @@
+package astcopy_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/vvakame/astcopy"
+)
+
+const lineDirectivesSrc = `package p
+
+func f() {}
+
+//line gen.go:10
+func g() {}
+
+func h() { /*line other.go:20:5*/ x := 1; _ = x }
+
+//line :30
+var v int
+
+var w = /*line x.go:1:1*/ 1 + /*line y.go:2:2*/ 2
+`
+
+func TestFileSet(t *testing.T) {
+	fset := token.NewFileSet()
+	fset.AddFile("empty.go", -1, 0)
+	if _, err := parser.ParseFile(fset, "a.go", lineDirectivesSrc, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	cp := astcopy.FileSet(fset)
+	if cp == fset {
+		t.Fatal("file set is not copied")
+	}
+	var files, cpFiles []*token.File
+	fset.Iterate(func(f *token.File) bool {
+		files = append(files, f)
+		return true
+	})
+	cp.Iterate(func(f *token.File) bool {
+		cpFiles = append(cpFiles, f)
+		return true
+	})
+	if len(files) != len(cpFiles) {
+		t.Fatalf("have %d files, want %d", len(cpFiles), len(files))
+	}
+	for i, f := range files {
+		checkTokenFile(t, f, cpFiles[i])
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	fset := token.NewFileSet()
+	a, err := parser.ParseFile(fset, "a.go", lineDirectivesSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(fset, "b.go", "package p", 0); err != nil {
+		t.Fatal(err)
+	}
+	c, err := parser.ParseFile(fset, "c.go", "package p\n\nvar c int\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nMap := astcopy.CopyNodeMap{}
+	cpFset, cps := astcopy.Snapshot(fset, []*ast.File{a, c}, nMap)
+	if len(cps) != 2 {
+		t.Fatalf("have %d files, want 2", len(cps))
+	}
+	if !astcopy.Equal(a, cps[0]) || !astcopy.Equal(c, cps[1]) {
+		t.Error("files are not equal to originals")
+	}
+	if nMap[cps[0]] != a || nMap[cps[1]] != c {
+		t.Error("files are not mapped to originals")
+	}
+	if cpFset.File(a.Pos()) == fset.File(a.Pos()) {
+		t.Error("token file is not copied")
+	}
+	var names []string
+	cpFset.Iterate(func(f *token.File) bool {
+		names = append(names, f.Name())
+		return true
+	})
+	if len(names) != 2 || names[0] != "a.go" || names[1] != "c.go" {
+		t.Errorf("have %v files, want [a.go c.go]", names)
+	}
+	checkTokenFile(t, fset.File(a.Pos()), cpFset.File(cps[0].Pos()))
+	checkTokenFile(t, fset.File(c.Pos()), cpFset.File(cps[1].Pos()))
+
+	// The snapshot stays valid after the original file set changes.
+	fset.RemoveFile(fset.File(a.Pos()))
+	fn := cps[0].Decls[1].(*ast.FuncDecl)
+	if have := cpFset.Position(fn.Pos()).String(); have != "gen.go:10" {
+		t.Errorf("have %s g position, want gen.go:10", have)
+	}
+}
+
+func checkTokenFile(t *testing.T, want, have *token.File) {
+	t.Helper()
+	if have.Name() != want.Name() || have.Base() != want.Base() || have.Size() != want.Size() {
+		t.Errorf("have %s %d %d file, want %s %d %d",
+			have.Name(), have.Base(), have.Size(), want.Name(), want.Base(), want.Size())
+		return
+	}
+	for offset := 0; offset <= want.Size(); offset++ {
+		for _, adjusted := range []bool{false, true} {
+			wantPos := want.PositionFor(want.Pos(offset), adjusted)
+			havePos := have.PositionFor(have.Pos(offset), adjusted)
+			if havePos != wantPos {
+				t.Errorf("%s: have %v position at offset %d, want %v", want.Name(), havePos, offset, wantPos)
+				return
+			}
+		}
+	}
+}
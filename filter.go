@@ -0,0 +1,461 @@
+package astcopy
+
+import (
+	"go/ast"
+)
+
+// FilterFile returns x deep copy trimmed like ast.FilterFile does,
+// without modifying x. Dropped declarations, specs and fields are not copied.
+// Reports whether there are any top-level declarations left.
+func FilterFile(x *ast.File, f ast.Filter, nMap CopyNodeMap) (*ast.File, bool) {
+	c := filterer{filter: f, nMap: nMap}
+	return c.file(x)
+}
+
+// FileExports returns x deep copy trimmed like ast.FileExports does,
+// without modifying x.
+// Reports whether there are exported declarations.
+func FileExports(x *ast.File, nMap CopyNodeMap) (*ast.File, bool) {
+	c := filterer{filter: ast.IsExported, export: true, nMap: nMap}
+	return c.file(x)
+}
+
+// FilterDecl returns x deep copy trimmed like ast.FilterDecl does,
+// without modifying x.
+// Reports whether there are any declared names left.
+func FilterDecl(x ast.Decl, f ast.Filter, nMap CopyNodeMap) (ast.Decl, bool) {
+	c := filterer{filter: f, nMap: nMap}
+	return c.decl(x, true)
+}
+
+// FilterPackage returns x deep copy with every file trimmed
+// like ast.FilterPackage does, without modifying x.
+// Reports whether there are any top-level declarations left.
+func FilterPackage(x *ast.Package, f ast.Filter, nMap CopyNodeMap) (*ast.Package, bool) {
+	c := filterer{filter: f, nMap: nMap}
+	return c.pkg(x)
+}
+
+// PackageExports returns x deep copy with every file trimmed
+// like ast.PackageExports does, without modifying x.
+// Reports whether there are exported declarations.
+func PackageExports(x *ast.Package, nMap CopyNodeMap) (*ast.Package, bool) {
+	c := filterer{filter: ast.IsExported, export: true, nMap: nMap}
+	return c.pkg(x)
+}
+
+// filterer mirrors go/ast filtering functions, but builds filtered copies.
+// Methods with build argument only report the filtering result
+// if build is false.
+type filterer struct {
+	filter ast.Filter
+	export bool
+	nMap   CopyNodeMap
+}
+
+func (c *filterer) record(cp, x ast.Node) {
+	if c.nMap != nil {
+		base := c.nMap[x]
+		if base == nil {
+			base = x
+		}
+		c.nMap[cp] = base
+	}
+}
+
+func (c *filterer) pkg(x *ast.Package) (*ast.Package, bool) {
+	if x == nil {
+		return nil, false
+	}
+	cp := *x
+	hasDecls := false
+	cp.Files = make(map[string]*ast.File)
+	for filename, f := range x.Files {
+		var ok bool
+		cp.Files[filename], ok = c.file(f)
+		if ok {
+			hasDecls = true
+		}
+	}
+	c.record(&cp, x)
+	return &cp, hasDecls
+}
+
+func (c *filterer) file(x *ast.File) (*ast.File, bool) {
+	if x == nil {
+		return nil, false
+	}
+	cp := *x
+	cp.Doc = CommentGroup(x.Doc, c.nMap)
+	cp.Name = Ident(x.Name, c.nMap)
+	cp.Decls = nil
+	if x.Decls != nil {
+		cp.Decls = []ast.Decl{}
+	}
+	for _, decl := range x.Decls {
+		if decl, ok := c.decl(decl, false); ok {
+			cp.Decls = append(cp.Decls, decl)
+		}
+	}
+	if x.Imports != nil {
+		cp.Imports = make([]*ast.ImportSpec, len(x.Imports))
+		for i := range x.Imports {
+			cp.Imports[i] = ImportSpec(x.Imports[i], c.nMap)
+		}
+	}
+	cp.Unresolved = IdentList(x.Unresolved, c.nMap)
+	if x.Comments != nil {
+		cp.Comments = make([]*ast.CommentGroup, len(x.Comments))
+		for i := range x.Comments {
+			cp.Comments[i] = CommentGroup(x.Comments[i], c.nMap)
+		}
+	}
+	c.record(&cp, x)
+	return &cp, len(cp.Decls) > 0
+}
+
+// decl returns nil for the declarations that don't pass the filter,
+// unless keepEmpty is set.
+func (c *filterer) decl(x ast.Decl, keepEmpty bool) (ast.Decl, bool) {
+	switch x := x.(type) {
+	case *ast.GenDecl:
+		var specs []ast.Spec
+		if x.Specs != nil {
+			specs = []ast.Spec{}
+		}
+		for _, spec := range x.Specs {
+			if spec, ok := c.spec(spec); ok {
+				specs = append(specs, spec)
+			}
+		}
+		ok := len(specs) > 0
+		if !ok && !keepEmpty {
+			return nil, false
+		}
+		cp := *x
+		cp.Doc = CommentGroup(x.Doc, c.nMap)
+		cp.Specs = specs
+		c.record(&cp, x)
+		return &cp, ok
+	case *ast.FuncDecl:
+		ok := c.filter(x.Name.Name)
+		if !ok && !keepEmpty {
+			return nil, false
+		}
+		return FuncDecl(x, c.nMap), ok
+	default:
+		if !keepEmpty {
+			return nil, false
+		}
+		return Decl(x, c.nMap), false
+	}
+}
+
+func (c *filterer) spec(x ast.Spec) (ast.Spec, bool) {
+	switch x := x.(type) {
+	case *ast.ValueSpec:
+		names := c.identList(x.Names)
+		if len(names) == 0 {
+			return nil, false
+		}
+		cp := *x
+		cp.Doc = CommentGroup(x.Doc, c.nMap)
+		cp.Names = names
+		if c.export {
+			cp.Type, _ = c.typ(x.Type, true)
+		} else {
+			cp.Type = copyExpr(x.Type, c.nMap)
+		}
+		cp.Values = c.exprList(x.Values)
+		cp.Comment = CommentGroup(x.Comment, c.nMap)
+		c.record(&cp, x)
+		return &cp, true
+	case *ast.TypeSpec:
+		if !c.filter(x.Name.Name) {
+			// For general filtering, the type is filtered even if
+			// the name is filtered out and the spec is kept
+			// if the type contains filtered elements.
+			if c.export {
+				return nil, false
+			}
+			if _, ok := c.typ(x.Type, false); !ok {
+				return nil, false
+			}
+		}
+		cp := *x
+		cp.Doc = CommentGroup(x.Doc, c.nMap)
+		cp.Name = Ident(x.Name, c.nMap)
+		cp.TypeParams = FieldList(x.TypeParams, c.nMap)
+		if c.export || !c.filter(x.Name.Name) {
+			cp.Type, _ = c.typ(x.Type, true)
+		} else {
+			cp.Type = copyExpr(x.Type, c.nMap)
+		}
+		cp.Comment = CommentGroup(x.Comment, c.nMap)
+		c.record(&cp, x)
+		return &cp, true
+	default:
+		return nil, false
+	}
+}
+
+func (c *filterer) identList(xs []*ast.Ident) []*ast.Ident {
+	var cp []*ast.Ident
+	if xs != nil {
+		cp = []*ast.Ident{}
+	}
+	for _, x := range xs {
+		if c.filter(x.Name) {
+			cp = append(cp, Ident(x, c.nMap))
+		}
+	}
+	return cp
+}
+
+func (c *filterer) exprList(xs []ast.Expr) []ast.Expr {
+	var cp []ast.Expr
+	if xs != nil {
+		cp = []ast.Expr{}
+	}
+	for _, x := range xs {
+		switch x := x.(type) {
+		case *ast.CompositeLit:
+			cp = append(cp, c.compositeLit(x))
+		case *ast.KeyValueExpr:
+			if key, ok := x.Key.(*ast.Ident); ok && !c.filter(key.Name) {
+				continue
+			}
+			lit, ok := x.Value.(*ast.CompositeLit)
+			if !ok {
+				cp = append(cp, KeyValueExpr(x, c.nMap))
+				continue
+			}
+			kv := *x
+			kv.Key = copyExpr(x.Key, c.nMap)
+			kv.Value = c.compositeLit(lit)
+			c.record(&kv, x)
+			cp = append(cp, &kv)
+		default:
+			cp = append(cp, copyExpr(x, c.nMap))
+		}
+	}
+	return cp
+}
+
+func (c *filterer) compositeLit(x *ast.CompositeLit) *ast.CompositeLit {
+	cp := *x
+	cp.Type = copyExpr(x.Type, c.nMap)
+	cp.Elts = c.exprList(x.Elts)
+	if len(cp.Elts) < len(x.Elts) {
+		cp.Incomplete = true
+	}
+	c.record(&cp, x)
+	return &cp
+}
+
+// typ reports whether x type contains names that pass the filter.
+func (c *filterer) typ(x ast.Expr, build bool) (ast.Expr, bool) {
+	switch x := x.(type) {
+	case *ast.Ident:
+		if !build {
+			return nil, c.filter(x.Name)
+		}
+		return Ident(x, c.nMap), c.filter(x.Name)
+	case *ast.ParenExpr:
+		inner, ok := c.typ(x.X, build)
+		if !build {
+			return nil, ok
+		}
+		cp := *x
+		cp.X = inner
+		c.record(&cp, x)
+		return &cp, ok
+	case *ast.ArrayType:
+		elt, ok := c.typ(x.Elt, build)
+		if !build {
+			return nil, ok
+		}
+		cp := *x
+		cp.Len = copyExpr(x.Len, c.nMap)
+		cp.Elt = elt
+		c.record(&cp, x)
+		return &cp, ok
+	case *ast.StructType:
+		fields, removed, ok := c.fieldList(x.Fields, build)
+		if !build {
+			return nil, ok
+		}
+		cp := *x
+		cp.Fields = fields
+		if removed {
+			cp.Incomplete = true
+		}
+		c.record(&cp, x)
+		return &cp, ok
+	case *ast.FuncType:
+		params, ok1 := c.paramList(x.Params, build)
+		results, ok2 := c.paramList(x.Results, build)
+		if !build {
+			return nil, ok1 || ok2
+		}
+		cp := *x
+		cp.TypeParams = FieldList(x.TypeParams, c.nMap)
+		cp.Params = params
+		cp.Results = results
+		c.record(&cp, x)
+		return &cp, ok1 || ok2
+	case *ast.InterfaceType:
+		methods, removed, ok := c.fieldList(x.Methods, build)
+		if !build {
+			return nil, ok
+		}
+		cp := *x
+		cp.Methods = methods
+		if removed {
+			cp.Incomplete = true
+		}
+		c.record(&cp, x)
+		return &cp, ok
+	case *ast.MapType:
+		key, ok1 := c.typ(x.Key, build)
+		value, ok2 := c.typ(x.Value, build)
+		if !build {
+			return nil, ok1 || ok2
+		}
+		cp := *x
+		cp.Key = key
+		cp.Value = value
+		c.record(&cp, x)
+		return &cp, ok1 || ok2
+	case *ast.ChanType:
+		value, ok := c.typ(x.Value, build)
+		if !build {
+			return nil, ok
+		}
+		cp := *x
+		cp.Value = value
+		c.record(&cp, x)
+		return &cp, ok
+	default:
+		if !build {
+			return nil, false
+		}
+		return copyExpr(x, c.nMap), false
+	}
+}
+
+// fieldList reports whether any fields were removed
+// and whether any fields are left.
+func (c *filterer) fieldList(x *ast.FieldList, build bool) (*ast.FieldList, bool, bool) {
+	if x == nil {
+		return nil, false, false
+	}
+	var list []*ast.Field
+	if x.List != nil && build {
+		list = []*ast.Field{}
+	}
+	removed := false
+	n := 0
+	for _, f := range x.List {
+		keep := false
+		var names []*ast.Ident
+		if len(f.Names) == 0 {
+			name := embeddedFieldName(f.Type)
+			keep = name != nil && c.filter(name.Name)
+		} else {
+			kept := 0
+			for _, name := range f.Names {
+				if c.filter(name.Name) {
+					kept++
+					if build {
+						names = append(names, Ident(name, c.nMap))
+					}
+				}
+			}
+			if kept < len(f.Names) {
+				removed = true
+			}
+			keep = kept > 0
+		}
+		if !keep {
+			continue
+		}
+		n++
+		if !build {
+			continue
+		}
+		cp := *f
+		cp.Doc = CommentGroup(f.Doc, c.nMap)
+		cp.Names = names
+		if c.export {
+			cp.Type, _ = c.typ(f.Type, true)
+		} else {
+			cp.Type = copyExpr(f.Type, c.nMap)
+		}
+		cp.Tag = BasicLit(f.Tag, c.nMap)
+		cp.Comment = CommentGroup(f.Comment, c.nMap)
+		c.record(&cp, f)
+		list = append(list, &cp)
+	}
+	if n < len(x.List) {
+		removed = true
+	}
+	if !build {
+		return nil, removed, n > 0
+	}
+	cp := *x
+	cp.List = list
+	c.record(&cp, x)
+	return &cp, removed, n > 0
+}
+
+func (c *filterer) paramList(x *ast.FieldList, build bool) (*ast.FieldList, bool) {
+	if x == nil {
+		return nil, false
+	}
+	ok := false
+	var list []*ast.Field
+	if x.List != nil && build {
+		list = make([]*ast.Field, len(x.List))
+	}
+	for i, f := range x.List {
+		typ, fieldOK := c.typ(f.Type, build)
+		if fieldOK {
+			ok = true
+		}
+		if !build {
+			continue
+		}
+		cp := *f
+		cp.Doc = CommentGroup(f.Doc, c.nMap)
+		cp.Names = IdentList(f.Names, c.nMap)
+		cp.Type = typ
+		cp.Tag = BasicLit(f.Tag, c.nMap)
+		cp.Comment = CommentGroup(f.Comment, c.nMap)
+		c.record(&cp, f)
+		list[i] = &cp
+	}
+	if !build {
+		return nil, ok
+	}
+	cp := *x
+	cp.List = list
+	c.record(&cp, x)
+	return &cp, ok
+}
+
+// embeddedFieldName returns the name of an embedded field with x type,
+// like ast.fieldName does.
+func embeddedFieldName(x ast.Expr) *ast.Ident {
+	switch t := x.(type) {
+	case *ast.Ident:
+		return t
+	case *ast.SelectorExpr:
+		if _, ok := t.X.(*ast.Ident); ok {
+			return t.Sel
+		}
+	case *ast.StarExpr:
+		return embeddedFieldName(t.X)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package astcopy_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/vvakame/astcopy"
+)
+
+const filterSrc = `package p
+
+import "fmt"
+
+// A is exported.
+type A struct {
+	X, y int
+	*B
+	c    string
+	Fn   func(b B, d struct{ E, f int }) map[B]chan c
+}
+
+type b interface {
+	M()
+	n()
+}
+
+type c struct{ Z int }
+
+var V, w = T{X: 1, y: 2, Z: []T{{X: 3, y: 4}}}, 0
+
+const (
+	K = iota
+	l
+)
+
+func F() { fmt.Println() }
+
+func g() {}
+
+func (A) M() {}
+`
+
+func keepNames(name string) bool {
+	return name == "A" || name == "B" || name == "X" || name == "Z" || name == "l" || name == "F"
+}
+
+func TestFilter(t *testing.T) {
+	files := []string{"p.go"}
+	goroot, err := filepath.Glob(filepath.Join(runtime.GOROOT(), "src", "go", "ast", "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, goroot...)
+
+	for _, name := range files {
+		var src interface{}
+		if name == "p.go" {
+			src = filterSrc
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		orig := astcopy.File(f, nil)
+
+		checkFilter(t, name+": FilterFile", f, func(nMap astcopy.CopyNodeMap) (ast.Node, bool) {
+			return astcopy.FilterFile(f, keepNames, nMap)
+		}, func(x *ast.File) bool {
+			return ast.FilterFile(x, keepNames)
+		})
+		checkFilter(t, name+": FileExports", f, func(nMap astcopy.CopyNodeMap) (ast.Node, bool) {
+			return astcopy.FileExports(f, nMap)
+		}, ast.FileExports)
+		checkFilter(t, name+": PackageExports", f, func(nMap astcopy.CopyNodeMap) (ast.Node, bool) {
+			pkg := &ast.Package{Files: map[string]*ast.File{name: f}}
+			cp, ok := astcopy.PackageExports(pkg, nMap)
+			return cp.Files[name], ok
+		}, ast.FileExports)
+		for i, decl := range f.Decls {
+			checkFilter(t, name+": FilterDecl", f, func(nMap astcopy.CopyNodeMap) (ast.Node, bool) {
+				cp, ok := astcopy.FilterDecl(decl, keepNames, nMap)
+				return &ast.File{Decls: []ast.Decl{cp}}, ok
+			}, func(x *ast.File) bool {
+				decl := x.Decls[i]
+				ok := ast.FilterDecl(decl, keepNames)
+				*x = ast.File{Decls: []ast.Decl{decl}}
+				return ok
+			})
+		}
+
+		if !astcopy.Equal(f, orig) {
+			t.Errorf("%s: input is modified", name)
+		}
+	}
+}
+
+func checkFilter(t *testing.T, name string, f *ast.File, filter func(astcopy.CopyNodeMap) (ast.Node, bool), inPlace func(*ast.File) bool) {
+	t.Helper()
+
+	nMap := astcopy.CopyNodeMap{}
+	have, haveOK := filter(nMap)
+	wantMap := astcopy.CopyNodeMap{}
+	want := astcopy.File(f, wantMap)
+	wantOK := inPlace(want)
+	if haveOK != wantOK {
+		t.Errorf("%s: have %v result, want %v", name, haveOK, wantOK)
+	}
+	if !astcopy.Equal(have, want) {
+		t.Errorf("%s: filtered copy differs from in-place filtering result", name)
+	}
+
+	// Only the nodes kept by in-place filtering are copied.
+	kept := make(map[ast.Node]bool)
+	keep := func(n ast.Node) bool {
+		if n != nil {
+			kept[wantMap[n]] = true
+		}
+		return true
+	}
+	ast.Inspect(want, keep)
+	for _, spec := range want.Imports {
+		ast.Inspect(spec, keep)
+	}
+	for _, cg := range want.Comments {
+		ast.Inspect(cg, keep)
+	}
+	for _, id := range want.Unresolved {
+		ast.Inspect(id, keep)
+	}
+	for _, orig := range nMap {
+		if _, ok := orig.(*ast.Package); !ok && !kept[orig] {
+			t.Errorf("%s: dropped %T node is copied", name, orig)
+			return
+		}
+	}
+}
+
+func TestFilterFileNilSlices(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "p.go", "package p\n\nfunc F() {}\n", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, _ := astcopy.FilterFile(f, ast.IsExported, nil)
+	if cp.Imports != nil || cp.Comments != nil {
+		t.Errorf("have %#v imports and %#v comments, want nil", cp.Imports, cp.Comments)
+	}
+}
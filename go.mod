@@ -1,6 +1,15 @@
 module github.com/vvakame/astcopy
 
+go 1.22.0
+
 require (
+	github.com/dave/dst v0.27.3
 	github.com/go-toolsmith/astequal v1.0.0
 	github.com/go-toolsmith/strparse v1.0.0
+	golang.org/x/tools v0.30.0
+)
+
+require (
+	golang.org/x/mod v0.23.0 // indirect
+	golang.org/x/sync v0.11.0 // indirect
 )
@@ -0,0 +1,315 @@
+package astcopy
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"strconv"
+)
+
+// GoCodeOption controls GoCode output.
+type GoCodeOption int
+
+const (
+	// GoCodePositions makes GoCode output include token.Pos fields.
+	GoCodePositions GoCodeOption = 1 << iota
+)
+
+// GoCode returns gofmt-ed Go expression source that constructs x copy,
+// like &ast.BinaryExpr{X: ..., Op: token.ADD, Y: ...}.
+// The expression refers to the go/ast and go/token packages.
+//
+// Zero-valued fields are omitted, positions are omitted unless
+// GoCodePositions option is given. Without it the positions whose validity
+// matters, CallExpr.Ellipsis, TypeSpec.Assign and GenDecl parens,
+// are generated as token.Pos(1).
+// Ident.Obj links and scopes are not generated and nodes referenced more
+// than once, like File.Imports elements, are generated at every reference.
+func GoCode(x ast.Node, opts ...GoCodeOption) ([]byte, error) {
+	var mode GoCodeOption
+	for _, opt := range opts {
+		mode |= opt
+	}
+	g := goCoder{positions: mode&GoCodePositions != 0}
+	g.node(orNil(x))
+	return format.Source(g.buf.Bytes())
+}
+
+type goCoder struct {
+	buf       bytes.Buffer
+	positions bool
+}
+
+func (g *goCoder) node(x ast.Node) {
+	if x == nil {
+		g.buf.WriteString("nil")
+		return
+	}
+
+	g.buf.WriteString("&ast.")
+	g.buf.WriteString(nodeName(x))
+	g.buf.WriteString("{")
+	empty := true
+	key := func(name string) {
+		if empty {
+			g.buf.WriteString("\n")
+			empty = false
+		}
+		g.buf.WriteString(name)
+		g.buf.WriteString(": ")
+	}
+
+	for _, f := range nodeFields(x) {
+		switch ptr := f.ptr.(type) {
+		case *token.Pos:
+			if *ptr == token.NoPos {
+				continue
+			}
+			pos := *ptr
+			if !g.positions {
+				if !meaningfulPos(x, f.name) {
+					continue
+				}
+				pos = 1
+			}
+			key(f.name)
+			g.buf.WriteString("token.Pos(" + strconv.Itoa(int(pos)) + ")")
+		case *token.Token:
+			if *ptr == token.ILLEGAL {
+				continue
+			}
+			key(f.name)
+			g.buf.WriteString(tokenCode(*ptr))
+		case *string:
+			if *ptr == "" {
+				continue
+			}
+			key(f.name)
+			g.buf.WriteString(strconv.Quote(*ptr))
+		case *bool:
+			if !*ptr {
+				continue
+			}
+			key(f.name)
+			g.buf.WriteString("true")
+		case *ast.ChanDir:
+			if *ptr == 0 {
+				continue
+			}
+			key(f.name)
+			g.buf.WriteString(chanDirCode(*ptr))
+		case **ast.Object:
+			// Objects are not generated.
+			continue
+		default:
+			if isNodeField(ptr) {
+				child := getNode(ptr)
+				if child == nil {
+					continue
+				}
+				key(f.name)
+				g.node(child)
+			} else {
+				list := getList(ptr)
+				if list == nil {
+					continue
+				}
+				key(f.name)
+				g.list(listTypeCode(ptr), list)
+			}
+		}
+		g.buf.WriteString(",\n")
+	}
+
+	if x, ok := x.(*ast.Package); ok && x.Files != nil {
+		key("Files")
+		g.buf.WriteString("map[string]*ast.File{")
+		for _, name := range sortedFileNames(x) {
+			g.buf.WriteString("\n")
+			g.buf.WriteString(strconv.Quote(name))
+			g.buf.WriteString(": ")
+			g.node(orNil(x.Files[name]))
+			g.buf.WriteString(",")
+		}
+		g.buf.WriteString("\n},\n")
+	}
+
+	g.buf.WriteString("}")
+}
+
+// meaningfulPos reports whether the validity of the x position field
+// called name changes the code x denotes, like CallExpr.Ellipsis
+// tells f(xs...) from f(xs).
+func meaningfulPos(x ast.Node, name string) bool {
+	switch x.(type) {
+	case *ast.CallExpr:
+		return name == "Ellipsis"
+	case *ast.TypeSpec:
+		return name == "Assign"
+	case *ast.GenDecl:
+		return name == "Lparen" || name == "Rparen"
+	}
+	return false
+}
+
+func (g *goCoder) list(typ string, xs []ast.Node) {
+	g.buf.WriteString(typ)
+	g.buf.WriteString("{")
+	for _, x := range xs {
+		g.buf.WriteString("\n")
+		g.node(x)
+		g.buf.WriteString(",")
+	}
+	if len(xs) != 0 {
+		g.buf.WriteString("\n")
+	}
+	g.buf.WriteString("}")
+}
+
+func listTypeCode(ptr interface{}) string {
+	switch ptr.(type) {
+	case *[]ast.Expr:
+		return "[]ast.Expr"
+	case *[]ast.Stmt:
+		return "[]ast.Stmt"
+	case *[]ast.Decl:
+		return "[]ast.Decl"
+	case *[]ast.Spec:
+		return "[]ast.Spec"
+	case *[]*ast.Ident:
+		return "[]*ast.Ident"
+	case *[]*ast.Field:
+		return "[]*ast.Field"
+	case *[]*ast.Comment:
+		return "[]*ast.Comment"
+	case *[]*ast.ImportSpec:
+		return "[]*ast.ImportSpec"
+	case *[]*ast.CommentGroup:
+		return "[]*ast.CommentGroup"
+	default:
+		panic("unhandled list field")
+	}
+}
+
+func chanDirCode(dir ast.ChanDir) string {
+	switch dir {
+	case ast.SEND:
+		return "ast.SEND"
+	case ast.RECV:
+		return "ast.RECV"
+	case ast.SEND | ast.RECV:
+		return "ast.SEND | ast.RECV"
+	default:
+		return "ast.ChanDir(" + strconv.Itoa(int(dir)) + ")"
+	}
+}
+
+func tokenCode(tok token.Token) string {
+	if name, ok := tokenNames[tok]; ok {
+		return "token." + name
+	}
+	return "token.Token(" + strconv.Itoa(int(tok)) + ")"
+}
+
+// tokenNames maps tokens to their go/token constant names.
+var tokenNames = map[token.Token]string{
+	token.ILLEGAL: "ILLEGAL",
+	token.EOF:     "EOF",
+	token.COMMENT: "COMMENT",
+
+	token.IDENT:  "IDENT",
+	token.INT:    "INT",
+	token.FLOAT:  "FLOAT",
+	token.IMAG:   "IMAG",
+	token.CHAR:   "CHAR",
+	token.STRING: "STRING",
+
+	token.ADD: "ADD",
+	token.SUB: "SUB",
+	token.MUL: "MUL",
+	token.QUO: "QUO",
+	token.REM: "REM",
+
+	token.AND:     "AND",
+	token.OR:      "OR",
+	token.XOR:     "XOR",
+	token.SHL:     "SHL",
+	token.SHR:     "SHR",
+	token.AND_NOT: "AND_NOT",
+
+	token.ADD_ASSIGN: "ADD_ASSIGN",
+	token.SUB_ASSIGN: "SUB_ASSIGN",
+	token.MUL_ASSIGN: "MUL_ASSIGN",
+	token.QUO_ASSIGN: "QUO_ASSIGN",
+	token.REM_ASSIGN: "REM_ASSIGN",
+
+	token.AND_ASSIGN:     "AND_ASSIGN",
+	token.OR_ASSIGN:      "OR_ASSIGN",
+	token.XOR_ASSIGN:     "XOR_ASSIGN",
+	token.SHL_ASSIGN:     "SHL_ASSIGN",
+	token.SHR_ASSIGN:     "SHR_ASSIGN",
+	token.AND_NOT_ASSIGN: "AND_NOT_ASSIGN",
+
+	token.LAND:  "LAND",
+	token.LOR:   "LOR",
+	token.ARROW: "ARROW",
+	token.INC:   "INC",
+	token.DEC:   "DEC",
+
+	token.EQL:    "EQL",
+	token.LSS:    "LSS",
+	token.GTR:    "GTR",
+	token.ASSIGN: "ASSIGN",
+	token.NOT:    "NOT",
+
+	token.NEQ:      "NEQ",
+	token.LEQ:      "LEQ",
+	token.GEQ:      "GEQ",
+	token.DEFINE:   "DEFINE",
+	token.ELLIPSIS: "ELLIPSIS",
+
+	token.LPAREN: "LPAREN",
+	token.LBRACK: "LBRACK",
+	token.LBRACE: "LBRACE",
+	token.COMMA:  "COMMA",
+	token.PERIOD: "PERIOD",
+
+	token.RPAREN:    "RPAREN",
+	token.RBRACK:    "RBRACK",
+	token.RBRACE:    "RBRACE",
+	token.SEMICOLON: "SEMICOLON",
+	token.COLON:     "COLON",
+
+	token.BREAK:    "BREAK",
+	token.CASE:     "CASE",
+	token.CHAN:     "CHAN",
+	token.CONST:    "CONST",
+	token.CONTINUE: "CONTINUE",
+
+	token.DEFAULT:     "DEFAULT",
+	token.DEFER:       "DEFER",
+	token.ELSE:        "ELSE",
+	token.FALLTHROUGH: "FALLTHROUGH",
+	token.FOR:         "FOR",
+
+	token.FUNC:   "FUNC",
+	token.GO:     "GO",
+	token.GOTO:   "GOTO",
+	token.IF:     "IF",
+	token.IMPORT: "IMPORT",
+
+	token.INTERFACE: "INTERFACE",
+	token.MAP:       "MAP",
+	token.PACKAGE:   "PACKAGE",
+	token.RANGE:     "RANGE",
+	token.RETURN:    "RETURN",
+
+	token.SELECT: "SELECT",
+	token.STRUCT: "STRUCT",
+	token.SWITCH: "SWITCH",
+	token.TYPE:   "TYPE",
+	token.VAR:    "VAR",
+
+	token.TILDE: "TILDE",
+}
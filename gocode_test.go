@@ -0,0 +1,163 @@
+package astcopy_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/go-toolsmith/strparse"
+	"github.com/vvakame/astcopy"
+)
+
+func TestGoCode(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`x`, `&ast.Ident{
+	Name: "x",
+}`},
+		{`a + 1`, `&ast.BinaryExpr{
+	X: &ast.Ident{
+		Name: "a",
+	},
+	Op: token.ADD,
+	Y: &ast.BasicLit{
+		Kind:  token.INT,
+		Value: "1",
+	},
+}`},
+		{`f()`, `&ast.CallExpr{
+	Fun: &ast.Ident{
+		Name: "f",
+	},
+}`},
+		{`[]chan<- int{}`, `&ast.CompositeLit{
+	Type: &ast.ArrayType{
+		Elt: &ast.ChanType{
+			Dir: ast.SEND,
+			Value: &ast.Ident{
+				Name: "int",
+			},
+		},
+	},
+}`},
+	}
+
+	for _, test := range tests {
+		code, err := astcopy.GoCode(strparse.Expr(test.src))
+		if err != nil {
+			t.Fatalf("GoCode(%q): %v", test.src, err)
+		}
+		if string(code) != test.want {
+			t.Errorf("GoCode(%q):\nhave:\n%s\nwant:\n%s", test.src, code, test.want)
+		}
+	}
+}
+
+func TestGoCodeMeaningfulPositions(t *testing.T) {
+	tests := []ast.Node{
+		strparse.Expr(`f(xs...)`),
+		strparse.Decl(`type A = int`),
+		strparse.Decl(`import ("fmt")`),
+		strparse.Decl(`var (x = 1)`),
+	}
+	for _, orig := range tests {
+		code, err := astcopy.GoCode(orig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		built := buildGoCode(t, code)
+		if !astcopy.Equal(orig, built, astcopy.IgnorePositions|astcopy.IgnoreObjects) {
+			t.Errorf("node built by\n%s\ndiffers from the original", code)
+		}
+	}
+}
+
+// goCodeTypes are the types buildGoCode can construct.
+var goCodeTypes = map[string]reflect.Type{
+	"Ident":      reflect.TypeOf(ast.Ident{}),
+	"BasicLit":   reflect.TypeOf(ast.BasicLit{}),
+	"CallExpr":   reflect.TypeOf(ast.CallExpr{}),
+	"GenDecl":    reflect.TypeOf(ast.GenDecl{}),
+	"TypeSpec":   reflect.TypeOf(ast.TypeSpec{}),
+	"ValueSpec":  reflect.TypeOf(ast.ValueSpec{}),
+	"ImportSpec": reflect.TypeOf(ast.ImportSpec{}),
+}
+
+// goCodeLists are the list types buildGoCode can construct.
+var goCodeLists = map[string]reflect.Type{
+	"[]ast.Expr":   reflect.TypeOf([]ast.Expr(nil)),
+	"[]ast.Spec":   reflect.TypeOf([]ast.Spec(nil)),
+	"[]*ast.Ident": reflect.TypeOf([]*ast.Ident(nil)),
+}
+
+// goCodeTokens are the token constants buildGoCode knows.
+var goCodeTokens = map[string]token.Token{
+	"INT":    token.INT,
+	"STRING": token.STRING,
+	"IMPORT": token.IMPORT,
+	"TYPE":   token.TYPE,
+	"VAR":    token.VAR,
+}
+
+// buildGoCode evaluates the GoCode output code.
+func buildGoCode(t *testing.T, code []byte) ast.Node {
+	t.Helper()
+	x, err := parser.ParseExpr(string(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var eval func(x ast.Expr) reflect.Value
+	eval = func(x ast.Expr) reflect.Value {
+		switch x := x.(type) {
+		case *ast.UnaryExpr:
+			lit := x.X.(*ast.CompositeLit)
+			typ, ok := goCodeTypes[lit.Type.(*ast.SelectorExpr).Sel.Name]
+			if !ok {
+				t.Fatalf("unknown type %s", lit.Type.(*ast.SelectorExpr).Sel.Name)
+			}
+			v := reflect.New(typ)
+			for _, elt := range lit.Elts {
+				kv := elt.(*ast.KeyValueExpr)
+				v.Elem().FieldByName(kv.Key.(*ast.Ident).Name).Set(eval(kv.Value))
+			}
+			return v
+		case *ast.CompositeLit:
+			typ, ok := goCodeLists[types.ExprString(x.Type)]
+			if !ok {
+				t.Fatalf("unknown list type %s", types.ExprString(x.Type))
+			}
+			v := reflect.MakeSlice(typ, 0, len(x.Elts))
+			for _, elt := range x.Elts {
+				v = reflect.Append(v, eval(elt))
+			}
+			return v
+		case *ast.CallExpr:
+			n, err := strconv.Atoi(x.Args[0].(*ast.BasicLit).Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return reflect.ValueOf(token.Pos(n))
+		case *ast.SelectorExpr:
+			tok, ok := goCodeTokens[x.Sel.Name]
+			if !ok {
+				t.Fatalf("unknown token %s", x.Sel.Name)
+			}
+			return reflect.ValueOf(tok)
+		case *ast.BasicLit:
+			s, err := strconv.Unquote(x.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return reflect.ValueOf(s)
+		}
+		t.Fatalf("unexpected %T", x)
+		return reflect.Value{}
+	}
+	return eval(x).Interface().(ast.Node)
+}
@@ -0,0 +1,682 @@
+package astcopy
+
+import (
+	"encoding/binary"
+	"go/ast"
+	"go/token"
+	"hash"
+	"hash/fnv"
+)
+
+// Hash returns x structural hash.
+//
+// Hash is consistent with Equal: nodes that are equal under some options
+// have the same hash under the same options.
+// The result does not depend on the process, so it can be persisted.
+func Hash(x ast.Node, opts ...CompareOption) uint64 {
+	h := hasher{mode: compareMode(opts), h: fnv.New64a()}
+	h.node(x)
+	return h.h.Sum64()
+}
+
+// Node kind tags written by hasher.
+// Tag values are part of the hash, so they must not be changed.
+const (
+	hashNil byte = iota
+	hashBadExpr
+	hashIdent
+	hashEllipsis
+	hashBasicLit
+	hashFuncLit
+	hashCompositeLit
+	hashParenExpr
+	hashSelectorExpr
+	hashIndexExpr
+	hashIndexListExpr
+	hashSliceExpr
+	hashTypeAssertExpr
+	hashCallExpr
+	hashStarExpr
+	hashUnaryExpr
+	hashBinaryExpr
+	hashKeyValueExpr
+	hashArrayType
+	hashStructType
+	hashField
+	hashFieldList
+	hashFuncType
+	hashInterfaceType
+	hashMapType
+	hashChanType
+	hashBadStmt
+	hashDeclStmt
+	hashEmptyStmt
+	hashLabeledStmt
+	hashExprStmt
+	hashSendStmt
+	hashIncDecStmt
+	hashAssignStmt
+	hashGoStmt
+	hashDeferStmt
+	hashReturnStmt
+	hashBranchStmt
+	hashBlockStmt
+	hashIfStmt
+	hashCaseClause
+	hashSwitchStmt
+	hashTypeSwitchStmt
+	hashCommClause
+	hashSelectStmt
+	hashForStmt
+	hashRangeStmt
+	hashImportSpec
+	hashValueSpec
+	hashTypeSpec
+	hashBadDecl
+	hashGenDecl
+	hashFuncDecl
+	hashComment
+	hashCommentGroup
+	hashFile
+	hashPackage
+	hashObject
+)
+
+type hasher struct {
+	mode CompareOption
+	h    hash.Hash64
+	buf  [binary.MaxVarintLen64]byte
+}
+
+func (h *hasher) tag(tag byte) {
+	h.buf[0] = tag
+	h.h.Write(h.buf[:1])
+}
+
+func (h *hasher) int(x int64) {
+	n := binary.PutVarint(h.buf[:], x)
+	h.h.Write(h.buf[:n])
+}
+
+func (h *hasher) bool(x bool) {
+	if x {
+		h.int(1)
+	} else {
+		h.int(0)
+	}
+}
+
+func (h *hasher) string(s string) {
+	h.int(int64(len(s)))
+	h.h.Write([]byte(s))
+}
+
+func (h *hasher) pos(x token.Pos) {
+	if h.mode&IgnorePositions == 0 {
+		h.int(int64(x))
+	}
+}
+
+func (h *hasher) obj(x *ast.Object) {
+	if h.mode&IgnoreObjects != 0 {
+		return
+	}
+	if x == nil {
+		h.tag(hashNil)
+		return
+	}
+	h.tag(hashObject)
+	h.int(int64(x.Kind))
+	h.string(x.Name)
+}
+
+func (h *hasher) node(x ast.Node) {
+	switch x := x.(type) {
+	case nil:
+		h.tag(hashNil)
+	case ast.Expr:
+		h.expr(x)
+	case ast.Stmt:
+		h.stmt(x)
+	case ast.Decl:
+		h.decl(x)
+
+	case ast.Spec:
+		h.spec(x)
+	case *ast.Field:
+		h.field(x)
+	case *ast.FieldList:
+		h.fieldList(x)
+	case *ast.Comment:
+		h.comment(x)
+	case *ast.CommentGroup:
+		h.commentGroup(x)
+	case *ast.File:
+		h.file(x)
+	case *ast.Package:
+		h.pkg(x)
+
+	default:
+		panic("unhandled node")
+	}
+}
+
+func (h *hasher) exprList(xs []ast.Expr) {
+	h.int(int64(len(xs)))
+	for _, x := range xs {
+		h.expr(x)
+	}
+}
+
+func (h *hasher) stmtList(xs []ast.Stmt) {
+	h.int(int64(len(xs)))
+	for _, x := range xs {
+		h.stmt(x)
+	}
+}
+
+func (h *hasher) identList(xs []*ast.Ident) {
+	h.int(int64(len(xs)))
+	for _, x := range xs {
+		h.ident(x)
+	}
+}
+
+func (h *hasher) ident(x *ast.Ident) {
+	if x == nil {
+		h.tag(hashNil)
+		return
+	}
+	h.tag(hashIdent)
+	h.pos(x.NamePos)
+	h.string(x.Name)
+	h.obj(x.Obj)
+}
+
+func (h *hasher) basicLit(x *ast.BasicLit) {
+	if x == nil {
+		h.tag(hashNil)
+		return
+	}
+	h.tag(hashBasicLit)
+	h.pos(x.ValuePos)
+	h.pos(*valueEnd(x))
+	h.int(int64(x.Kind))
+	h.string(x.Value)
+}
+
+func (h *hasher) expr(x ast.Expr) {
+	if h.mode&IgnoreParens != 0 {
+		for {
+			p, ok := x.(*ast.ParenExpr)
+			if !ok || p == nil {
+				break
+			}
+			x = p.X
+		}
+	}
+
+	switch x := x.(type) {
+	case nil:
+		h.tag(hashNil)
+	case *ast.BadExpr:
+		h.tag(hashBadExpr)
+		h.pos(x.From)
+		h.pos(x.To)
+	case *ast.Ident:
+		h.ident(x)
+	case *ast.Ellipsis:
+		h.tag(hashEllipsis)
+		h.pos(x.Ellipsis)
+		h.expr(x.Elt)
+	case *ast.BasicLit:
+		h.basicLit(x)
+	case *ast.FuncLit:
+		h.tag(hashFuncLit)
+		h.funcType(x.Type)
+		h.blockStmt(x.Body)
+	case *ast.CompositeLit:
+		h.tag(hashCompositeLit)
+		h.expr(x.Type)
+		h.pos(x.Lbrace)
+		h.exprList(x.Elts)
+		h.pos(x.Rbrace)
+		h.bool(x.Incomplete)
+	case *ast.ParenExpr:
+		h.tag(hashParenExpr)
+		h.pos(x.Lparen)
+		h.expr(x.X)
+		h.pos(x.Rparen)
+	case *ast.SelectorExpr:
+		h.tag(hashSelectorExpr)
+		h.expr(x.X)
+		h.ident(x.Sel)
+	case *ast.IndexExpr:
+		h.tag(hashIndexExpr)
+		h.expr(x.X)
+		h.pos(x.Lbrack)
+		h.expr(x.Index)
+		h.pos(x.Rbrack)
+	case *ast.IndexListExpr:
+		h.tag(hashIndexListExpr)
+		h.expr(x.X)
+		h.pos(x.Lbrack)
+		h.exprList(x.Indices)
+		h.pos(x.Rbrack)
+	case *ast.SliceExpr:
+		h.tag(hashSliceExpr)
+		h.expr(x.X)
+		h.pos(x.Lbrack)
+		h.expr(x.Low)
+		h.expr(x.High)
+		h.expr(x.Max)
+		h.bool(x.Slice3)
+		h.pos(x.Rbrack)
+	case *ast.TypeAssertExpr:
+		h.tag(hashTypeAssertExpr)
+		h.expr(x.X)
+		h.pos(x.Lparen)
+		h.expr(x.Type)
+		h.pos(x.Rparen)
+	case *ast.CallExpr:
+		h.callExpr(x)
+	case *ast.StarExpr:
+		h.tag(hashStarExpr)
+		h.pos(x.Star)
+		h.expr(x.X)
+	case *ast.UnaryExpr:
+		h.tag(hashUnaryExpr)
+		h.pos(x.OpPos)
+		h.int(int64(x.Op))
+		h.expr(x.X)
+	case *ast.BinaryExpr:
+		h.tag(hashBinaryExpr)
+		h.expr(x.X)
+		h.pos(x.OpPos)
+		h.int(int64(x.Op))
+		h.expr(x.Y)
+	case *ast.KeyValueExpr:
+		h.tag(hashKeyValueExpr)
+		h.expr(x.Key)
+		h.pos(x.Colon)
+		h.expr(x.Value)
+	case *ast.ArrayType:
+		h.tag(hashArrayType)
+		h.pos(x.Lbrack)
+		h.expr(x.Len)
+		h.expr(x.Elt)
+	case *ast.StructType:
+		h.tag(hashStructType)
+		h.pos(x.Struct)
+		h.fieldList(x.Fields)
+		h.bool(x.Incomplete)
+	case *ast.FuncType:
+		h.funcType(x)
+	case *ast.InterfaceType:
+		h.tag(hashInterfaceType)
+		h.pos(x.Interface)
+		h.fieldList(x.Methods)
+		h.bool(x.Incomplete)
+	case *ast.MapType:
+		h.tag(hashMapType)
+		h.pos(x.Map)
+		h.expr(x.Key)
+		h.expr(x.Value)
+	case *ast.ChanType:
+		h.tag(hashChanType)
+		h.pos(x.Begin)
+		h.pos(x.Arrow)
+		h.int(int64(x.Dir))
+		h.expr(x.Value)
+
+	default:
+		panic("unhandled expr")
+	}
+}
+
+func (h *hasher) callExpr(x *ast.CallExpr) {
+	if x == nil {
+		h.tag(hashNil)
+		return
+	}
+	h.tag(hashCallExpr)
+	h.expr(x.Fun)
+	h.pos(x.Lparen)
+	h.exprList(x.Args)
+	h.bool(x.Ellipsis.IsValid())
+	h.pos(x.Ellipsis)
+	h.pos(x.Rparen)
+}
+
+func (h *hasher) field(x *ast.Field) {
+	if x == nil {
+		h.tag(hashNil)
+		return
+	}
+	h.tag(hashField)
+	h.docs(x.Doc)
+	h.identList(x.Names)
+	h.expr(x.Type)
+	h.basicLit(x.Tag)
+	h.docs(x.Comment)
+}
+
+func (h *hasher) fieldList(x *ast.FieldList) {
+	if x == nil {
+		h.tag(hashNil)
+		return
+	}
+	h.tag(hashFieldList)
+	h.pos(x.Opening)
+	h.int(int64(len(x.List)))
+	for _, f := range x.List {
+		h.field(f)
+	}
+	h.pos(x.Closing)
+}
+
+func (h *hasher) funcType(x *ast.FuncType) {
+	if x == nil {
+		h.tag(hashNil)
+		return
+	}
+	h.tag(hashFuncType)
+	h.pos(x.Func)
+	h.fieldList(x.TypeParams)
+	h.fieldList(x.Params)
+	h.fieldList(x.Results)
+}
+
+func (h *hasher) blockStmt(x *ast.BlockStmt) {
+	if x == nil {
+		h.tag(hashNil)
+		return
+	}
+	h.tag(hashBlockStmt)
+	h.pos(x.Lbrace)
+	h.stmtList(x.List)
+	h.pos(x.Rbrace)
+}
+
+func (h *hasher) stmt(x ast.Stmt) {
+	switch x := x.(type) {
+	case nil:
+		h.tag(hashNil)
+	case *ast.BadStmt:
+		h.tag(hashBadStmt)
+		h.pos(x.From)
+		h.pos(x.To)
+	case *ast.DeclStmt:
+		h.tag(hashDeclStmt)
+		h.decl(x.Decl)
+	case *ast.EmptyStmt:
+		h.tag(hashEmptyStmt)
+		h.pos(x.Semicolon)
+		h.bool(x.Implicit)
+	case *ast.LabeledStmt:
+		h.tag(hashLabeledStmt)
+		h.ident(x.Label)
+		h.pos(x.Colon)
+		h.stmt(x.Stmt)
+	case *ast.ExprStmt:
+		h.tag(hashExprStmt)
+		h.expr(x.X)
+	case *ast.SendStmt:
+		h.tag(hashSendStmt)
+		h.expr(x.Chan)
+		h.pos(x.Arrow)
+		h.expr(x.Value)
+	case *ast.IncDecStmt:
+		h.tag(hashIncDecStmt)
+		h.expr(x.X)
+		h.pos(x.TokPos)
+		h.int(int64(x.Tok))
+	case *ast.AssignStmt:
+		h.tag(hashAssignStmt)
+		h.exprList(x.Lhs)
+		h.pos(x.TokPos)
+		h.int(int64(x.Tok))
+		h.exprList(x.Rhs)
+	case *ast.GoStmt:
+		h.tag(hashGoStmt)
+		h.pos(x.Go)
+		h.callExpr(x.Call)
+	case *ast.DeferStmt:
+		h.tag(hashDeferStmt)
+		h.pos(x.Defer)
+		h.callExpr(x.Call)
+	case *ast.ReturnStmt:
+		h.tag(hashReturnStmt)
+		h.pos(x.Return)
+		h.exprList(x.Results)
+	case *ast.BranchStmt:
+		h.tag(hashBranchStmt)
+		h.pos(x.TokPos)
+		h.int(int64(x.Tok))
+		h.ident(x.Label)
+	case *ast.BlockStmt:
+		h.blockStmt(x)
+	case *ast.IfStmt:
+		h.tag(hashIfStmt)
+		h.pos(x.If)
+		h.stmt(x.Init)
+		h.expr(x.Cond)
+		h.blockStmt(x.Body)
+		h.stmt(x.Else)
+	case *ast.CaseClause:
+		h.tag(hashCaseClause)
+		h.pos(x.Case)
+		h.bool(x.List == nil)
+		h.exprList(x.List)
+		h.pos(x.Colon)
+		h.stmtList(x.Body)
+	case *ast.SwitchStmt:
+		h.tag(hashSwitchStmt)
+		h.pos(x.Switch)
+		h.stmt(x.Init)
+		h.expr(x.Tag)
+		h.blockStmt(x.Body)
+	case *ast.TypeSwitchStmt:
+		h.tag(hashTypeSwitchStmt)
+		h.pos(x.Switch)
+		h.stmt(x.Init)
+		h.stmt(x.Assign)
+		h.blockStmt(x.Body)
+	case *ast.CommClause:
+		h.tag(hashCommClause)
+		h.pos(x.Case)
+		h.stmt(x.Comm)
+		h.pos(x.Colon)
+		h.stmtList(x.Body)
+	case *ast.SelectStmt:
+		h.tag(hashSelectStmt)
+		h.pos(x.Select)
+		h.blockStmt(x.Body)
+	case *ast.ForStmt:
+		h.tag(hashForStmt)
+		h.pos(x.For)
+		h.stmt(x.Init)
+		h.expr(x.Cond)
+		h.stmt(x.Post)
+		h.blockStmt(x.Body)
+	case *ast.RangeStmt:
+		h.tag(hashRangeStmt)
+		h.pos(x.For)
+		h.expr(x.Key)
+		h.expr(x.Value)
+		h.pos(x.TokPos)
+		h.int(int64(x.Tok))
+		h.pos(x.Range)
+		h.expr(x.X)
+		h.blockStmt(x.Body)
+
+	default:
+		panic("unhandled stmt")
+	}
+}
+
+func (h *hasher) importSpec(x *ast.ImportSpec) {
+	if x == nil {
+		h.tag(hashNil)
+		return
+	}
+	h.tag(hashImportSpec)
+	h.docs(x.Doc)
+	h.ident(x.Name)
+	h.basicLit(x.Path)
+	h.docs(x.Comment)
+	h.pos(x.EndPos)
+}
+
+func (h *hasher) spec(x ast.Spec) {
+	switch x := x.(type) {
+	case nil:
+		h.tag(hashNil)
+	case *ast.ImportSpec:
+		h.importSpec(x)
+	case *ast.ValueSpec:
+		h.tag(hashValueSpec)
+		h.docs(x.Doc)
+		h.identList(x.Names)
+		h.expr(x.Type)
+		h.exprList(x.Values)
+		h.docs(x.Comment)
+	case *ast.TypeSpec:
+		h.tag(hashTypeSpec)
+		h.docs(x.Doc)
+		h.ident(x.Name)
+		h.fieldList(x.TypeParams)
+		h.bool(x.Assign.IsValid())
+		h.pos(x.Assign)
+		h.expr(x.Type)
+		h.docs(x.Comment)
+
+	default:
+		panic("unhandled spec")
+	}
+}
+
+func (h *hasher) decl(x ast.Decl) {
+	switch x := x.(type) {
+	case nil:
+		h.tag(hashNil)
+	case *ast.BadDecl:
+		h.tag(hashBadDecl)
+		h.pos(x.From)
+		h.pos(x.To)
+	case *ast.GenDecl:
+		h.tag(hashGenDecl)
+		h.docs(x.Doc)
+		h.pos(x.TokPos)
+		h.int(int64(x.Tok))
+		h.bool(x.Lparen.IsValid())
+		h.pos(x.Lparen)
+		h.int(int64(len(x.Specs)))
+		for _, spec := range x.Specs {
+			h.spec(spec)
+		}
+		h.pos(x.Rparen)
+	case *ast.FuncDecl:
+		h.tag(hashFuncDecl)
+		h.docs(x.Doc)
+		h.fieldList(x.Recv)
+		h.ident(x.Name)
+		h.funcType(x.Type)
+		h.blockStmt(x.Body)
+
+	default:
+		panic("unhandled decl")
+	}
+}
+
+// docs hashes Doc and Comment fields unless comments are ignored.
+func (h *hasher) docs(x *ast.CommentGroup) {
+	if h.mode&IgnoreComments == 0 {
+		h.commentGroup(x)
+	}
+}
+
+func (h *hasher) comment(x *ast.Comment) {
+	if x == nil {
+		h.tag(hashNil)
+		return
+	}
+	h.tag(hashComment)
+	h.pos(x.Slash)
+	h.string(x.Text)
+}
+
+func (h *hasher) commentGroup(x *ast.CommentGroup) {
+	if x == nil {
+		h.tag(hashNil)
+		return
+	}
+	h.tag(hashCommentGroup)
+	h.int(int64(len(x.List)))
+	for _, c := range x.List {
+		h.comment(c)
+	}
+}
+
+func (h *hasher) file(x *ast.File) {
+	if x == nil {
+		h.tag(hashNil)
+		return
+	}
+	h.tag(hashFile)
+	h.docs(x.Doc)
+	h.pos(x.Package)
+	h.ident(x.Name)
+	h.int(int64(len(x.Decls)))
+	for _, decl := range x.Decls {
+		h.decl(decl)
+	}
+	h.pos(x.FileStart)
+	h.pos(x.FileEnd)
+	h.string(x.GoVersion)
+	h.int(int64(len(x.Imports)))
+	for _, spec := range x.Imports {
+		h.importSpec(spec)
+	}
+	if h.mode&IgnoreObjects == 0 {
+		h.identList(x.Unresolved)
+	}
+	if h.mode&IgnoreComments == 0 {
+		h.int(int64(len(x.Comments)))
+		for _, c := range x.Comments {
+			h.commentGroup(c)
+		}
+	}
+}
+
+func (h *hasher) pkg(x *ast.Package) {
+	if x == nil {
+		h.tag(hashNil)
+		return
+	}
+	h.tag(hashPackage)
+	h.string(x.Name)
+
+	// Map iteration order is random, so files and imports
+	// are combined in an order-independent way.
+	h.int(int64(len(x.Files)))
+	var files uint64
+	for filename, f := range x.Files {
+		sub := hasher{mode: h.mode, h: fnv.New64a()}
+		sub.string(filename)
+		sub.file(f)
+		files += sub.h.Sum64()
+	}
+	h.int(int64(files))
+	if h.mode&IgnoreObjects == 0 {
+		h.int(int64(len(x.Imports)))
+		var imports uint64
+		for path, obj := range x.Imports {
+			sub := hasher{mode: h.mode, h: fnv.New64a()}
+			sub.string(path)
+			sub.obj(obj)
+			imports += sub.h.Sum64()
+		}
+		h.int(int64(imports))
+	}
+}
@@ -0,0 +1,58 @@
+package astcopy_test
+
+import (
+	"testing"
+
+	"github.com/go-toolsmith/strparse"
+	"github.com/vvakame/astcopy"
+)
+
+func TestHash(t *testing.T) {
+	exprs := []string{
+		`1 + 2`,
+		`1+2`,
+		`(1) + 2`,
+		`1 - 2`,
+		`f(xs...)`,
+		`f(xs)`,
+		`x.(type)`,
+		`[]int{1, 2}`,
+		`map[string]func(int) error{}`,
+		`func(x int) { return }`,
+		`func(y int) { return }`,
+		`<-chan int(nil)`,
+	}
+	optSets := [][]astcopy.CompareOption{
+		nil,
+		{astcopy.IgnorePositions},
+		{astcopy.IgnorePositions, astcopy.IgnoreParens},
+		{astcopy.IgnorePositions, astcopy.IgnoreObjects},
+	}
+
+	for _, opts := range optSets {
+		for _, x := range exprs {
+			for _, y := range exprs {
+				a, b := strparse.Expr(x), strparse.Expr(y)
+				equal := astcopy.Equal(a, b, opts...)
+				sameHash := astcopy.Hash(a, opts...) == astcopy.Hash(b, opts...)
+				if equal && !sameHash {
+					t.Errorf("%q and %q are equal with %v but hashes differ", x, y, opts)
+				}
+				if !equal && sameHash {
+					t.Errorf("%q and %q are not equal with %v but hashes collide", x, y, opts)
+				}
+			}
+		}
+	}
+}
+
+func TestHashPositions(t *testing.T) {
+	x := strparse.Stmt(`if a { b() }`)
+	y := strparse.Stmt(`if  a  {  b()  }`)
+	if astcopy.Hash(x) == astcopy.Hash(y) {
+		t.Error("hashes of nodes at different positions are equal")
+	}
+	if astcopy.Hash(x, astcopy.IgnorePositions) != astcopy.Hash(y, astcopy.IgnorePositions) {
+		t.Error("hashes with ignored positions differ")
+	}
+}
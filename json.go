@@ -0,0 +1,532 @@
+package astcopy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONOption controls MarshalJSON output.
+type JSONOption int
+
+const (
+	// JSONObjects makes MarshalJSON encode Ident.Obj links
+	// and Package.Imports objects.
+	JSONObjects JSONOption = 1 << iota
+)
+
+// MarshalJSON returns x JSON encoding.
+//
+// Every node is encoded as an object with the "_type" member holding
+// the node type name, like "BinaryExpr", and members named after
+// the node fields. Positions are encoded as token.Pos integers,
+// tokens as their strings, like "+" or "INT".
+// Nil nodes and nil slices are omitted.
+//
+// Nodes that are referenced more than once, like File.Imports elements,
+// are encoded once with an "_id" member; other references are encoded
+// as {"_ref": id}. If objects are encoded, the root object gets an
+// "_objects" member with object descriptions and Ident.Obj fields
+// hold object indexes in that list.
+// Scopes are never encoded.
+func MarshalJSON(x ast.Node, opts ...JSONOption) ([]byte, error) {
+	var mode JSONOption
+	for _, opt := range opts {
+		mode |= opt
+	}
+	x = orNil(x)
+	if x == nil {
+		return []byte("null"), nil
+	}
+
+	e := jsonEncoder{
+		objects: mode&JSONObjects != 0,
+		refs:    make(map[ast.Node]int),
+		ids:     make(map[ast.Node]int),
+		objIDs:  make(map[*ast.Object]int),
+	}
+	e.count(x)
+	for _, obj := range e.objs {
+		if decl, ok := obj.Decl.(ast.Node); ok && e.refs[decl] != 0 {
+			// Make sure the declaration gets an id.
+			e.refs[decl]++
+		}
+	}
+	e.node(x, true)
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.buf.Bytes(), nil
+}
+
+type jsonEncoder struct {
+	buf     bytes.Buffer
+	err     error
+	objects bool
+
+	// refs counts references to every node.
+	refs map[ast.Node]int
+	// ids holds ids of already encoded shared nodes.
+	ids map[ast.Node]int
+
+	objs   []*ast.Object
+	objIDs map[*ast.Object]int
+}
+
+// count fills reference counts and collects objects.
+func (e *jsonEncoder) count(x ast.Node) {
+	if x == nil {
+		return
+	}
+	e.refs[x]++
+	if e.refs[x] > 1 {
+		return
+	}
+	for _, f := range nodeFields(x) {
+		switch {
+		case isNodeField(f.ptr):
+			e.count(getNode(f.ptr))
+		case isListField(f.ptr):
+			for _, x := range getList(f.ptr) {
+				e.count(x)
+			}
+		default:
+			if obj, ok := f.ptr.(**ast.Object); ok {
+				e.object(*obj)
+			}
+		}
+	}
+	if x, ok := x.(*ast.Package); ok {
+		for _, name := range sortedFileNames(x) {
+			e.count(x.Files[name])
+		}
+		for _, path := range sortedImportPaths(x) {
+			e.object(x.Imports[path])
+		}
+	}
+}
+
+func (e *jsonEncoder) object(obj *ast.Object) {
+	if !e.objects || obj == nil {
+		return
+	}
+	if _, ok := e.objIDs[obj]; !ok {
+		e.objIDs[obj] = len(e.objs)
+		e.objs = append(e.objs, obj)
+	}
+}
+
+func (e *jsonEncoder) str(s string) {
+	b, err := json.Marshal(s)
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+	e.buf.Write(b)
+}
+
+func (e *jsonEncoder) key(name string) {
+	e.buf.WriteByte(',')
+	e.str(name)
+	e.buf.WriteByte(':')
+}
+
+func (e *jsonEncoder) node(x ast.Node, root bool) {
+	if x == nil {
+		e.buf.WriteString("null")
+		return
+	}
+	if id, ok := e.ids[x]; ok {
+		fmt.Fprintf(&e.buf, `{"_ref":%d}`, id)
+		return
+	}
+
+	e.buf.WriteString(`{"_type":`)
+	e.str(nodeName(x))
+	if e.refs[x] > 1 {
+		id := len(e.ids)
+		e.ids[x] = id
+		e.key("_id")
+		e.buf.WriteString(strconv.Itoa(id))
+	}
+
+	for _, f := range nodeFields(x) {
+		switch ptr := f.ptr.(type) {
+		case *token.Pos:
+			e.key(f.name)
+			e.buf.WriteString(strconv.Itoa(int(*ptr)))
+		case *token.Token:
+			e.key(f.name)
+			e.str(ptr.String())
+		case *string:
+			e.key(f.name)
+			e.str(*ptr)
+		case *bool:
+			e.key(f.name)
+			e.buf.WriteString(strconv.FormatBool(*ptr))
+		case *ast.ChanDir:
+			e.key(f.name)
+			e.buf.WriteString(strconv.Itoa(int(*ptr)))
+		case **ast.Object:
+			if id, ok := e.objIDs[*ptr]; ok {
+				e.key(f.name)
+				e.buf.WriteString(strconv.Itoa(id))
+			}
+		default:
+			if isNodeField(ptr) {
+				if child := getNode(ptr); child != nil {
+					e.key(f.name)
+					e.node(child, false)
+				}
+				continue
+			}
+			list := getList(ptr)
+			if list == nil {
+				continue
+			}
+			e.key(f.name)
+			e.buf.WriteByte('[')
+			for i, x := range list {
+				if i != 0 {
+					e.buf.WriteByte(',')
+				}
+				e.node(x, false)
+			}
+			e.buf.WriteByte(']')
+		}
+	}
+
+	if x, ok := x.(*ast.Package); ok {
+		e.key("Files")
+		e.buf.WriteByte('{')
+		for i, name := range sortedFileNames(x) {
+			if i != 0 {
+				e.buf.WriteByte(',')
+			}
+			e.str(name)
+			e.buf.WriteByte(':')
+			e.node(x.Files[name], false)
+		}
+		e.buf.WriteByte('}')
+		if e.objects && x.Imports != nil {
+			e.key("Imports")
+			e.buf.WriteByte('{')
+			for i, path := range sortedImportPaths(x) {
+				if i != 0 {
+					e.buf.WriteByte(',')
+				}
+				e.str(path)
+				e.buf.WriteByte(':')
+				e.buf.WriteString(strconv.Itoa(e.objIDs[x.Imports[path]]))
+			}
+			e.buf.WriteByte('}')
+		}
+	}
+
+	if root && e.objects {
+		e.key("_objects")
+		e.buf.WriteByte('[')
+		for i, obj := range e.objs {
+			if i != 0 {
+				e.buf.WriteByte(',')
+			}
+			e.buf.WriteString(`{"Kind":`)
+			e.str(obj.Kind.String())
+			e.key("Name")
+			e.str(obj.Name)
+			if decl, ok := obj.Decl.(ast.Node); ok {
+				if id, ok := e.ids[decl]; ok {
+					e.key("Decl")
+					e.buf.WriteString(strconv.Itoa(id))
+				}
+			}
+			if data, ok := obj.Data.(int); ok {
+				e.key("Data")
+				e.buf.WriteString(strconv.Itoa(data))
+			}
+			e.buf.WriteByte('}')
+		}
+		e.buf.WriteByte(']')
+	}
+
+	e.buf.WriteByte('}')
+}
+
+// UnmarshalJSON decodes a node encoded by MarshalJSON.
+func UnmarshalJSON(data []byte) (ast.Node, error) {
+	var root map[string]json.RawMessage
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	if root == nil {
+		return nil, nil
+	}
+
+	d := jsonDecoder{ids: make(map[int]ast.Node)}
+	if raw, ok := root["_objects"]; ok {
+		if err := d.objects(raw); err != nil {
+			return nil, err
+		}
+	}
+	x, err := d.node(root)
+	if err != nil {
+		return nil, err
+	}
+	for i, obj := range d.objs {
+		if id, ok := d.decls[i]; ok {
+			decl, ok := d.ids[id]
+			if !ok {
+				return nil, fmt.Errorf("astcopy: object %q refers to unknown node %d", obj.Name, id)
+			}
+			obj.Decl = decl
+		}
+	}
+	return x, nil
+}
+
+type jsonDecoder struct {
+	ids   map[int]ast.Node
+	objs  []*ast.Object
+	decls map[int]int
+}
+
+func (d *jsonDecoder) objects(raw json.RawMessage) error {
+	var objs []struct {
+		Kind string
+		Name string
+		Decl *int
+		Data *int
+	}
+	if err := json.Unmarshal(raw, &objs); err != nil {
+		return err
+	}
+	d.decls = make(map[int]int)
+	for i, o := range objs {
+		kind, ok := objKindByName[o.Kind]
+		if !ok {
+			return fmt.Errorf("astcopy: unknown object kind %q", o.Kind)
+		}
+		obj := ast.NewObj(kind, o.Name)
+		if o.Data != nil {
+			obj.Data = *o.Data
+		}
+		if o.Decl != nil {
+			d.decls[i] = *o.Decl
+		}
+		d.objs = append(d.objs, obj)
+	}
+	return nil
+}
+
+func (d *jsonDecoder) raw(raw json.RawMessage) (ast.Node, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &members); err != nil {
+		return nil, err
+	}
+	if members == nil {
+		return nil, nil
+	}
+	return d.node(members)
+}
+
+func (d *jsonDecoder) node(members map[string]json.RawMessage) (ast.Node, error) {
+	if raw, ok := members["_ref"]; ok {
+		var id int
+		if err := json.Unmarshal(raw, &id); err != nil {
+			return nil, err
+		}
+		x, ok := d.ids[id]
+		if !ok {
+			return nil, fmt.Errorf("astcopy: reference to unknown node %d", id)
+		}
+		return x, nil
+	}
+
+	var typ string
+	if err := json.Unmarshal(members["_type"], &typ); err != nil {
+		return nil, errors.New("astcopy: node without valid _type")
+	}
+	x := newNode(typ)
+	if x == nil {
+		return nil, fmt.Errorf("astcopy: unknown node type %q", typ)
+	}
+	if raw, ok := members["_id"]; ok {
+		var id int
+		if err := json.Unmarshal(raw, &id); err != nil {
+			return nil, err
+		}
+		d.ids[id] = x
+	}
+
+	for _, f := range nodeFields(x) {
+		raw, ok := members[f.name]
+		if !ok {
+			continue
+		}
+		if err := d.field(f.ptr, raw); err != nil {
+			return nil, fmt.Errorf("astcopy: %s.%s: %v", typ, f.name, err)
+		}
+	}
+
+	if x, ok := x.(*ast.Package); ok {
+		if err := d.pkg(x, members); err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+func (d *jsonDecoder) field(ptr interface{}, raw json.RawMessage) error {
+	switch ptr := ptr.(type) {
+	case *token.Pos:
+		var pos int
+		if err := json.Unmarshal(raw, &pos); err != nil {
+			return err
+		}
+		*ptr = token.Pos(pos)
+	case *token.Token:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return err
+		}
+		tok, ok := tokenByName[s]
+		if !ok {
+			return fmt.Errorf("unknown token %q", s)
+		}
+		*ptr = tok
+	case *string:
+		return json.Unmarshal(raw, ptr)
+	case *bool:
+		return json.Unmarshal(raw, ptr)
+	case *ast.ChanDir:
+		var dir int
+		if err := json.Unmarshal(raw, &dir); err != nil {
+			return err
+		}
+		*ptr = ast.ChanDir(dir)
+	case **ast.Object:
+		var id int
+		if err := json.Unmarshal(raw, &id); err != nil {
+			return err
+		}
+		if id < 0 || id >= len(d.objs) {
+			return fmt.Errorf("unknown object %d", id)
+		}
+		*ptr = d.objs[id]
+	default:
+		if isNodeField(ptr) {
+			x, err := d.raw(raw)
+			if err != nil {
+				return err
+			}
+			if !fits(ptr, x) {
+				return fmt.Errorf("unexpected %s node", nodeName(x))
+			}
+			setNode(ptr, x)
+			return nil
+		}
+
+		var elems []json.RawMessage
+		if err := json.Unmarshal(raw, &elems); err != nil {
+			return err
+		}
+		if elems == nil {
+			return nil
+		}
+		list := make([]ast.Node, len(elems))
+		for i, raw := range elems {
+			x, err := d.raw(raw)
+			if err != nil {
+				return err
+			}
+			if !fits(ptr, x) {
+				return fmt.Errorf("unexpected %s node", nodeName(x))
+			}
+			list[i] = x
+		}
+		setList(ptr, list)
+	}
+	return nil
+}
+
+func (d *jsonDecoder) pkg(x *ast.Package, members map[string]json.RawMessage) error {
+	var files map[string]json.RawMessage
+	if err := json.Unmarshal(members["Files"], &files); err != nil {
+		return err
+	}
+	x.Files = make(map[string]*ast.File, len(files))
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	// Files must be decoded in the encoding order to resolve references.
+	sort.Strings(names)
+	for _, name := range names {
+		f, err := d.raw(files[name])
+		if err != nil {
+			return err
+		}
+		file, ok := f.(*ast.File)
+		if !ok {
+			return fmt.Errorf("astcopy: Package.Files: unexpected %s node", nodeName(f))
+		}
+		x.Files[name] = file
+	}
+
+	raw, ok := members["Imports"]
+	if !ok {
+		return nil
+	}
+	var imports map[string]int
+	if err := json.Unmarshal(raw, &imports); err != nil {
+		return err
+	}
+	x.Imports = make(map[string]*ast.Object, len(imports))
+	for path, id := range imports {
+		if id < 0 || id >= len(d.objs) {
+			return fmt.Errorf("astcopy: Package.Imports: unknown object %d", id)
+		}
+		x.Imports[path] = d.objs[id]
+	}
+	return nil
+}
+
+func sortedFileNames(x *ast.Package) []string {
+	names := make([]string, 0, len(x.Files))
+	for name := range x.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedImportPaths(x *ast.Package) []string {
+	paths := make([]string, 0, len(x.Imports))
+	for path := range x.Imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+var (
+	tokenByName   = make(map[string]token.Token)
+	objKindByName = make(map[string]ast.ObjKind)
+)
+
+func init() {
+	// Token ranges have gaps, so all small values are checked.
+	for tok := token.ILLEGAL; tok < 256; tok++ {
+		if s := tok.String(); !strings.HasPrefix(s, "token(") {
+			tokenByName[s] = tok
+		}
+	}
+	for kind := ast.Bad; kind <= ast.Lbl; kind++ {
+		objKindByName[kind.String()] = kind
+	}
+}
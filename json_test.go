@@ -0,0 +1,84 @@
+package astcopy_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/vvakame/astcopy"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	const src = `// Package p is p.
+package p
+
+import (
+	"fmt" // for Println
+	str "strings"
+)
+
+const (
+	a = iota
+	b
+)
+
+type T[E any] struct {
+	x, y int ` + "`json:\"x\"`" + `
+	ch   <-chan E
+}
+
+// F does things.
+func (t *T[E]) F(xs ...int) (err error) {
+loop:
+	for i, x := range xs {
+		switch {
+		case x > 1:
+			continue loop
+		default:
+			fmt.Println(str.ToUpper("x"), i, xs[1:2:3], t.ch)
+		}
+	}
+	return nil
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opts := range [][]astcopy.JSONOption{nil, {astcopy.JSONObjects}} {
+		data, err := astcopy.MarshalJSON(f, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x, err := astcopy.UnmarshalJSON(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g := x.(*ast.File)
+
+		cmpOpts := []astcopy.CompareOption{astcopy.IgnoreObjects}
+		if len(opts) != 0 {
+			cmpOpts = nil
+		}
+		if !astcopy.Equal(f, g, cmpOpts...) {
+			t.Errorf("decoded file is not equal to the original (opts=%v)", opts)
+		}
+
+		// Shared nodes must stay shared.
+		if g.Imports[0] != g.Decls[0].(*ast.GenDecl).Specs[0] {
+			t.Error("import spec is not shared")
+		}
+		if g.Doc != g.Comments[0] {
+			t.Error("doc comment is not shared")
+		}
+		if len(opts) != 0 {
+			obj := g.Decls[2].(*ast.GenDecl).Specs[0].(*ast.TypeSpec).Name.Obj
+			if obj == nil || obj.Decl != g.Decls[2].(*ast.GenDecl).Specs[0] {
+				t.Error("type object is not linked to its declaration")
+			}
+		}
+	}
+}
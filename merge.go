@@ -0,0 +1,90 @@
+package astcopy
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// MergePackageFiles returns a file merged from x files like
+// ast.MergePackageFiles does, but from x files deep copies,
+// so the result shares no nodes with x and x is not modified.
+//
+// nMap maps copied nodes of all files to their originals.
+// Nodes created by merging, like the merged file and its doc comment
+// group, have no originals.
+func MergePackageFiles(x *ast.Package, mode ast.MergeMode, nMap CopyNodeMap) *ast.File {
+	if x == nil {
+		return nil
+	}
+	pkg := Package(x, nMap)
+	for filename, f := range pkg.Files {
+		pkg.Files[filename] = linkedImportsFile(f, nMap)
+	}
+	return ast.MergePackageFiles(pkg, mode)
+}
+
+// SortImports returns x deep copy with imports sorted
+// like ast.SortImports does, x is not modified.
+// The copy positions are updated like ast.SortImports updates them,
+// so they are valid in fset only for printing.
+func SortImports(fset *token.FileSet, x *ast.File, nMap CopyNodeMap) *ast.File {
+	if x == nil {
+		return nil
+	}
+	cp := linkedImportsFile(x, nMap)
+	ast.SortImports(fset, cp)
+
+	// ast.SortImports updates File.Imports only since go1.23,
+	// before that the removed duplicates stay there.
+	cp.Imports = cp.Imports[:0]
+	for _, decl := range cp.Decls {
+		if decl, ok := decl.(*ast.GenDecl); ok && decl.Tok == token.IMPORT {
+			for _, spec := range decl.Specs {
+				cp.Imports = append(cp.Imports, spec.(*ast.ImportSpec))
+			}
+		}
+	}
+	return cp
+}
+
+// linkedImportsFile returns x deep copy with File.Imports elements
+// shared with the copied import declarations specs,
+// as the functions of go/ast expect.
+func linkedImportsFile(x *ast.File, nMap CopyNodeMap) *ast.File {
+	noImports := *x
+	noImports.Imports = nil
+	cp := File(&noImports, nMap)
+	if nMap != nil {
+		base := nMap[x]
+		if base == nil {
+			base = x
+		}
+		nMap[cp] = base
+	}
+
+	specs := make(map[*ast.ImportSpec]*ast.ImportSpec)
+	for i, decl := range x.Decls {
+		decl, ok := decl.(*ast.GenDecl)
+		if !ok || decl.Tok != token.IMPORT {
+			continue
+		}
+		cpDecl := cp.Decls[i].(*ast.GenDecl)
+		for j, spec := range decl.Specs {
+			if spec, ok := spec.(*ast.ImportSpec); ok {
+				specs[spec] = cpDecl.Specs[j].(*ast.ImportSpec)
+			}
+		}
+	}
+	cp.Imports = nil
+	if x.Imports != nil {
+		cp.Imports = make([]*ast.ImportSpec, len(x.Imports))
+	}
+	for i, spec := range x.Imports {
+		if cpSpec := specs[spec]; cpSpec != nil {
+			cp.Imports[i] = cpSpec
+		} else {
+			cp.Imports[i] = ImportSpec(spec, nMap)
+		}
+	}
+	return cp
+}
@@ -0,0 +1,158 @@
+package astcopy_test
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"sort"
+	"testing"
+
+	"github.com/vvakame/astcopy"
+)
+
+func TestMergePackageFiles(t *testing.T) {
+	srcs := map[string]string{
+		"a.go": `// Package p is a.
+package p
+
+import "fmt"
+
+// F is a.
+func F() { fmt.Println("a") }
+
+func g() {}
+`,
+		"b.go": `// Package p is b.
+package p
+
+import (
+	"fmt"
+	"os"
+)
+
+func F() { fmt.Println("b", os.Args) }
+
+// h is b.
+func h() {}
+`,
+	}
+	parse := func() (*token.FileSet, *ast.Package) {
+		fset := token.NewFileSet()
+		pkg := &ast.Package{Name: "p", Files: map[string]*ast.File{}}
+		// The files are parsed in name order, so both file sets
+		// get the same file bases.
+		names := make([]string, 0, len(srcs))
+		for name := range srcs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			f, err := parser.ParseFile(fset, name, srcs[name], parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pkg.Files[name] = f
+		}
+		return fset, pkg
+	}
+
+	const mode = ast.FilterFuncDuplicates | ast.FilterUnassociatedComments | ast.FilterImportDuplicates
+	fset, pkg := parse()
+	origs := make(map[ast.Node]bool)
+	for _, f := range pkg.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			origs[n] = true
+			return true
+		})
+	}
+	before := astcopy.Package(pkg, nil)
+	for name, f := range before.Files {
+		before.Files[name] = astcopy.File(f, nil)
+	}
+
+	nMap := astcopy.CopyNodeMap{}
+	have := astcopy.MergePackageFiles(pkg, mode, nMap)
+	wantFset, wantPkg := parse()
+	want := ast.MergePackageFiles(wantPkg, mode)
+
+	if a, b := printNode(t, fset, have), printNode(t, wantFset, want); a != b {
+		t.Errorf("merged files differ:\nhave:\n%s\nwant:\n%s", a, b)
+	}
+	for name, f := range pkg.Files {
+		if !astcopy.Equal(f, before.Files[name]) {
+			t.Errorf("%s is modified", name)
+		}
+	}
+	ast.Inspect(have, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		if origs[n] {
+			t.Errorf("%T node is shared with the input", n)
+		}
+		if orig := nMap[n]; orig != nil && !origs[orig] {
+			t.Errorf("%T node is mapped to a node outside the input", n)
+		}
+		return true
+	})
+	for _, spec := range have.Imports {
+		if !origs[nMap[spec]] {
+			t.Errorf("import %s is not mapped to the input", spec.Path.Value)
+		}
+	}
+}
+
+func TestSortImports(t *testing.T) {
+	const src = `package p
+
+import (
+	"os" // os
+	"fmt"
+	"fmt"
+
+	"strings"
+	"bytes"
+)
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := astcopy.File(f, nil)
+
+	nMap := astcopy.CopyNodeMap{}
+	have := astcopy.SortImports(fset, f, nMap)
+	wantFset := token.NewFileSet()
+	want, err := parser.ParseFile(wantFset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast.SortImports(wantFset, want)
+
+	if a, b := printNode(t, fset, have), printNode(t, wantFset, want); a != b {
+		t.Errorf("sorted files differ:\nhave:\n%s\nwant:\n%s", a, b)
+	}
+	if !astcopy.Equal(f, before) {
+		t.Error("input is modified")
+	}
+	if len(have.Imports) != 4 {
+		t.Fatalf("have %d imports, want 4", len(have.Imports))
+	}
+	for i, spec := range have.Imports {
+		if spec == f.Imports[i] || nMap[spec] == nil {
+			t.Errorf("import %s is not a mapped copy", spec.Path.Value)
+		}
+	}
+}
+
+func printNode(t *testing.T, fset *token.FileSet, x ast.Node) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, x); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
@@ -0,0 +1,155 @@
+// Package pkgcopy copies golang.org/x/tools/go/packages results,
+// so speculative changes can be made to a snapshot of a loaded package
+// without affecting the loaded graph.
+package pkgcopy
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/vvakame/astcopy"
+)
+
+// Snapshot is a copy of a loaded package and its importers.
+type Snapshot struct {
+	// Fset is the copy of the packages file set with the files of
+	// the copied packages only. Their positions of the original file set
+	// are valid in it, positions of the other packages are not.
+	Fset *token.FileSet
+	// Packages maps the copied packages to their copies.
+	Packages map[*packages.Package]*packages.Package
+	// NodeMap maps the copied nodes to their originals.
+	NodeMap astcopy.CopyNodeMap
+}
+
+// NewSnapshot copies pkg and the packages of graph that import pkg,
+// directly or indirectly.
+// graph is the list of loaded root packages, pkg must be one of them
+// or one of their dependencies.
+//
+// The copies have Fset replaced by the file set copy, Syntax replaced
+// by the file copies and TypesInfo replaced by the info with the nodes
+// remapped to the copies. Their Imports refer to the copied packages.
+// Types, TypesSizes, the types.Info objects and the other fields
+// are shared with the originals, so the snapshot stays valid
+// only as long as the copied trees are consistent with Types.
+func NewSnapshot(graph []*packages.Package, pkg *packages.Package) *Snapshot {
+	affected := map[*packages.Package]bool{pkg: true}
+	order := []*packages.Package{pkg}
+	packages.Visit(graph, nil, func(p *packages.Package) {
+		if affected[p] {
+			return
+		}
+		for _, imp := range p.Imports {
+			if affected[imp] {
+				affected[p] = true
+				order = append(order, p)
+				return
+			}
+		}
+	})
+
+	var files []*ast.File
+	for _, p := range order {
+		files = append(files, p.Syntax...)
+	}
+	s := &Snapshot{
+		Packages: make(map[*packages.Package]*packages.Package, len(order)),
+		NodeMap:  astcopy.CopyNodeMap{},
+	}
+	var cpFiles []*ast.File
+	s.Fset, cpFiles = astcopy.Snapshot(pkg.Fset, files, s.NodeMap)
+	for _, p := range order {
+		cp := *p
+		if cp.Fset != nil {
+			cp.Fset = s.Fset
+		}
+		s.Packages[p] = &cp
+	}
+	for _, p := range order {
+		cp := s.Packages[p]
+		copies := make(map[ast.Node]ast.Node)
+		if p.Syntax != nil {
+			cp.Syntax, cpFiles = cpFiles[:len(p.Syntax):len(p.Syntax)], cpFiles[len(p.Syntax):]
+			for _, f := range cp.Syntax {
+				recordCopies(copies, f, s.NodeMap)
+			}
+		}
+		cp.TypesInfo = remapInfo(p.TypesInfo, copies)
+		if p.Imports != nil {
+			cp.Imports = make(map[string]*packages.Package, len(p.Imports))
+			for path, imp := range p.Imports {
+				if impCopy, ok := s.Packages[imp]; ok {
+					imp = impCopy
+				}
+				cp.Imports[path] = imp
+			}
+		}
+	}
+	return s
+}
+
+// recordCopies adds the original nodes of the cp nodes to copies.
+// File.Imports elements have copies of their own, the declaration
+// copies are recorded for them, like for all nodes copied more than once.
+func recordCopies(copies map[ast.Node]ast.Node, cp *ast.File, nMap astcopy.CopyNodeMap) {
+	ast.Inspect(cp, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		if orig, ok := nMap[n]; ok {
+			if _, ok := copies[orig]; !ok {
+				copies[orig] = n
+			}
+		}
+		return true
+	})
+}
+
+// remapInfo returns info with the node keys replaced by their copies.
+// Nodes without copies are dropped.
+func remapInfo(info *types.Info, copies map[ast.Node]ast.Node) *types.Info {
+	if info == nil {
+		return nil
+	}
+	cp := &types.Info{
+		Types:        remap(info.Types, copies),
+		Instances:    remap(info.Instances, copies),
+		Defs:         remap(info.Defs, copies),
+		Uses:         remap(info.Uses, copies),
+		Implicits:    remap(info.Implicits, copies),
+		Selections:   remap(info.Selections, copies),
+		Scopes:       remap(info.Scopes, copies),
+		FileVersions: remap(info.FileVersions, copies),
+	}
+	if info.InitOrder != nil {
+		cp.InitOrder = make([]*types.Initializer, len(info.InitOrder))
+		for i, init := range info.InitOrder {
+			cpInit := *init
+			if rhs, ok := copies[init.Rhs].(ast.Expr); ok {
+				cpInit.Rhs = rhs
+			}
+			cp.InitOrder[i] = &cpInit
+		}
+	}
+	return cp
+}
+
+func remap[K interface {
+	comparable
+	ast.Node
+}, V any](m map[K]V, copies map[ast.Node]ast.Node) map[K]V {
+	if m == nil {
+		return nil
+	}
+	cp := make(map[K]V, len(m))
+	for n, v := range m {
+		if cpNode, ok := copies[n].(K); ok {
+			cp[cpNode] = v
+		}
+	}
+	return cp
+}
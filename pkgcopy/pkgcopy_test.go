@@ -0,0 +1,171 @@
+package pkgcopy_test
+
+import (
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/vvakame/astcopy"
+	"github.com/vvakame/astcopy/pkgcopy"
+)
+
+var moduleFiles = map[string]string{
+	"go.mod": "module example.com/m\n\ngo 1.22\n",
+	"a/a.go": `package a
+
+import "fmt"
+
+// Greeting is the greeting format.
+const Greeting = "hello, %s"
+
+func Hello(name string) string {
+	return fmt.Sprintf(Greeting, name)
+}
+`,
+	"b/b.go": `package b
+
+import "example.com/m/a"
+
+var World = a.Hello("world")
+`,
+	"c/c.go": `package c
+
+import "example.com/m/b"
+
+func Print() { println(b.World) }
+`,
+	"d/d.go": `package d
+
+func Unrelated() {}
+`,
+}
+
+func loadModule(t *testing.T) []*packages.Package {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range moduleFiles {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps |
+			packages.NeedTypes | packages.NeedSyntax | packages.NeedTypesInfo,
+		Dir: dir,
+	}
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if packages.PrintErrors(pkgs) != 0 {
+		t.Fatal("packages have errors")
+	}
+	return pkgs
+}
+
+func TestNewSnapshot(t *testing.T) {
+	pkgs := loadModule(t)
+	byPath := make(map[string]*packages.Package)
+	packages.Visit(pkgs, nil, func(p *packages.Package) {
+		byPath[p.PkgPath] = p
+	})
+	a, b, c := byPath["example.com/m/a"], byPath["example.com/m/b"], byPath["example.com/m/c"]
+
+	s := pkgcopy.NewSnapshot(pkgs, a)
+	if len(s.Packages) != 3 {
+		t.Fatalf("have %d copied packages, want a, b and c", len(s.Packages))
+	}
+	for _, orig := range []*packages.Package{a, b, c} {
+		cp := s.Packages[orig]
+		if cp == nil {
+			t.Fatalf("%s is not copied", orig.PkgPath)
+		}
+		if cp.Fset != s.Fset || cp.Types != orig.Types {
+			t.Errorf("%s: Fset is not replaced or Types is not shared", orig.PkgPath)
+		}
+		checkPackage(t, s, orig, cp)
+	}
+	var files []string
+	s.Fset.Iterate(func(f *token.File) bool {
+		files = append(files, filepath.Base(f.Name()))
+		return true
+	})
+	if len(files) != 3 || files[0] != "a.go" || files[1] != "b.go" || files[2] != "c.go" {
+		t.Errorf("have %v snapshot files, want [a.go b.go c.go]", files)
+	}
+	if cp := s.Packages[b].Imports["example.com/m/a"]; cp != s.Packages[a] {
+		t.Error("b imports the original a")
+	}
+	if fmtPkg := s.Packages[a].Imports["fmt"]; fmtPkg != a.Imports["fmt"] {
+		t.Error("fmt is copied")
+	}
+
+	// The copy can be changed without affecting the original.
+	aCopy := s.Packages[a]
+	hello := aCopy.Syntax[0].Decls[2].(*ast.FuncDecl)
+	hello.Name.Name = "Greet"
+	if a.Syntax[0].Decls[2].(*ast.FuncDecl).Name.Name != "Hello" {
+		t.Error("original is modified")
+	}
+	if obj := aCopy.TypesInfo.Defs[hello.Name]; obj == nil || obj.Name() != "Hello" {
+		t.Errorf("have %v renamed function object, want Hello", obj)
+	}
+}
+
+// checkPackage checks that cp syntax is copied from orig with the types
+// info remapped and the positions valid in the snapshot file set.
+func checkPackage(t *testing.T, s *pkgcopy.Snapshot, orig, cp *packages.Package) {
+	t.Helper()
+	origs := make(map[ast.Node]bool)
+	for _, f := range orig.Syntax {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if n != nil {
+				origs[n] = true
+			}
+			return true
+		})
+	}
+	if len(cp.Syntax) != len(orig.Syntax) {
+		t.Fatalf("%s: have %d files, want %d", orig.PkgPath, len(cp.Syntax), len(orig.Syntax))
+	}
+	for i, f := range cp.Syntax {
+		if !astcopy.Equal(orig.Syntax[i], f) {
+			t.Errorf("%s: file %d copy differs", orig.PkgPath, i)
+		}
+		if have, want := s.Fset.Position(f.Pos()), orig.Fset.Position(orig.Syntax[i].Pos()); have != want {
+			t.Errorf("%s: have %v position, want %v", orig.PkgPath, have, want)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			if origs[n] {
+				t.Fatalf("%s: %T node is not copied", orig.PkgPath, n)
+			}
+			return true
+		})
+	}
+
+	info, cpInfo := orig.TypesInfo, cp.TypesInfo
+	if len(cpInfo.Types) != len(info.Types) || len(cpInfo.Defs) != len(info.Defs) ||
+		len(cpInfo.Uses) != len(info.Uses) || len(cpInfo.Implicits) != len(info.Implicits) ||
+		len(cpInfo.Selections) != len(info.Selections) || len(cpInfo.Scopes) != len(info.Scopes) ||
+		len(cpInfo.InitOrder) != len(info.InitOrder) || len(cpInfo.FileVersions) != len(info.FileVersions) {
+		t.Errorf("%s: types info entries are lost", orig.PkgPath)
+	}
+	for id, obj := range cpInfo.Uses {
+		if origs[id] || info.Uses[s.NodeMap[id].(*ast.Ident)] != obj {
+			t.Errorf("%s: %s use is not remapped", orig.PkgPath, id.Name)
+		}
+	}
+	for _, init := range cpInfo.InitOrder {
+		if origs[init.Rhs] {
+			t.Errorf("%s: %s initializer is not remapped", orig.PkgPath, init)
+		}
+	}
+}
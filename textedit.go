@@ -0,0 +1,258 @@
+package astcopy
+
+import (
+	"bytes"
+	"errors"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// TextEdit is a replacement of the [Pos, End) source range with NewText.
+// It has the same layout as analysis.TextEdit from golang.org/x/tools.
+type TextEdit struct {
+	Pos     token.Pos
+	End     token.Pos
+	NewText []byte
+}
+
+// TextEdits returns source edits that turn the orig source into
+// the edited tree source, where edited is a mutated copy of orig.
+//
+// nMap must map edited nodes to their original nodes, see Diff.
+// Only the smallest printable subtrees enclosing changes are printed.
+// Comments inside reprinted subtrees are kept only if orig is *ast.File.
+func TextEdits(fset *token.FileSet, orig, edited ast.Node, nMap CopyNodeMap) ([]TextEdit, error) {
+	diff := Diff(orig, edited, nMap)
+	if len(diff) == 0 {
+		return nil, nil
+	}
+
+	g := textEditor{
+		fset:    fset,
+		nMap:    nMap,
+		known:   make(map[ast.Node]bool),
+		parents: make(map[ast.Node]ast.Node),
+		copies:  make(map[ast.Node]ast.Node),
+	}
+	var stack []ast.Node
+	ast.Inspect(orig, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		g.known[n] = true
+		if len(stack) != 0 {
+			g.parents[n] = stack[len(stack)-1]
+		}
+		stack = append(stack, n)
+		return true
+	})
+	ast.Inspect(edited, func(n ast.Node) bool {
+		if n != nil {
+			base := nMap[n]
+			if base == nil {
+				base = n
+			}
+			g.copies[base] = n
+		}
+		return true
+	})
+	if f, ok := edited.(*ast.File); ok {
+		g.comments = f.Comments
+	}
+
+	dirty := make(map[ast.Node]bool)
+	for _, e := range diff {
+		var n ast.Node
+		switch {
+		case e.Parent == nil:
+			n = orig
+		case e.Kind == NodeReplaced && e.Old != nil && e.New != nil:
+			n = e.Old
+		default:
+			if _, ok := e.Parent.(*ast.File); ok {
+				switch e.Field {
+				case "Imports", "Unresolved", "Comments":
+					// These fields duplicate information stored
+					// in the other fields and have no own source text.
+					continue
+				}
+			}
+			n = e.Parent
+		}
+		n = g.printable(n)
+		if n == nil {
+			return nil, errors.New("astcopy: can't find printable node for the edit")
+		}
+		dirty[n] = true
+	}
+
+	var edits []TextEdit
+	for n := range dirty {
+		if g.hasDirtyParent(n, dirty) {
+			continue
+		}
+		text, err := g.print(n)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, TextEdit{Pos: n.Pos(), End: n.End(), NewText: text})
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].Pos < edits[j].Pos
+	})
+	return edits, nil
+}
+
+type textEditor struct {
+	fset *token.FileSet
+	nMap CopyNodeMap
+
+	// known is a set of original nodes.
+	known map[ast.Node]bool
+	// parents maps original nodes to their parents.
+	parents map[ast.Node]ast.Node
+	// copies maps original nodes to their edited counterparts.
+	copies map[ast.Node]ast.Node
+	// comments are the edited file comments.
+	comments []*ast.CommentGroup
+}
+
+// printable returns the smallest original node that encloses n,
+// has a valid source range, an edited counterpart and can be printed.
+func (g *textEditor) printable(n ast.Node) ast.Node {
+	for ; n != nil; n = g.parents[n] {
+		if !n.Pos().IsValid() || !n.End().IsValid() || g.copies[n] == nil {
+			continue
+		}
+		switch n.(type) {
+		case ast.Expr, ast.Stmt, ast.Decl, ast.Spec, *ast.File, *ast.CommentGroup:
+			return n
+		}
+	}
+	return nil
+}
+
+func (g *textEditor) hasDirtyParent(n ast.Node, dirty map[ast.Node]bool) bool {
+	for p := g.parents[n]; p != nil; p = g.parents[p] {
+		if dirty[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// print returns the n edited counterpart source text.
+func (g *textEditor) print(n ast.Node) ([]byte, error) {
+	cp := g.detach(g.copies[n])
+
+	if cg, ok := cp.(*ast.CommentGroup); ok {
+		lines := make([]string, len(cg.List))
+		for i, c := range cg.List {
+			lines[i] = c.Text
+		}
+		indent := "\n" + strings.Repeat("\t", g.indent(n))
+		return []byte(strings.Join(lines, indent)), nil
+	}
+
+	var node interface{} = cp
+	if g.comments != nil {
+		var comments []*ast.CommentGroup
+		for _, c := range g.comments {
+			if c.Pos() >= n.Pos() && c.End() <= n.End() {
+				comments = append(comments, c)
+			}
+		}
+		node = &printer.CommentedNode{Node: cp, Comments: comments}
+	}
+
+	indent := g.indent(n)
+	cfg := printer.Config{
+		Mode:     printer.UseSpaces | printer.TabIndent,
+		Tabwidth: 8,
+		Indent:   indent,
+	}
+	var buf bytes.Buffer
+	if err := cfg.Fprint(&buf, g.fset, node); err != nil {
+		return nil, err
+	}
+	// The original text before n already contains the first line indentation.
+	return bytes.TrimPrefix(buf.Bytes(), bytes.Repeat([]byte("\t"), indent)), nil
+}
+
+// detach returns x copy with positions cleared in the nodes
+// that are not copies of the original nodes.
+// Positions of such nodes are meaningless for the original file set.
+func (g *textEditor) detach(x ast.Node) ast.Node {
+	m := make(CopyNodeMap)
+	cp := Node(x, m)
+	for n, base := range m {
+		if orig := g.nMap[base]; orig != nil {
+			base = orig
+		}
+		if g.known[base] {
+			continue
+		}
+		for _, f := range nodeFields(n) {
+			if pos, ok := f.ptr.(*token.Pos); ok {
+				*pos = token.NoPos
+			}
+		}
+	}
+	return cp
+}
+
+// indent returns gofmt indentation level of n.
+func (g *textEditor) indent(n ast.Node) int {
+	indent := 0
+	for child, p := n, g.parents[n]; p != nil; child, p = p, g.parents[p] {
+		switch p := p.(type) {
+		case *ast.BlockStmt:
+			switch g.parents[p].(type) {
+			case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+				// Case clauses are not indented.
+			default:
+				indent++
+			}
+		case *ast.CaseClause, *ast.CommClause:
+			for _, stmt := range caseBody(p) {
+				if stmt == child {
+					indent++
+					break
+				}
+			}
+		case *ast.FieldList:
+			switch g.parents[p].(type) {
+			case *ast.StructType, *ast.InterfaceType:
+				indent++
+			}
+		case *ast.GenDecl:
+			if p.Lparen.IsValid() {
+				indent++
+			}
+		case *ast.CompositeLit:
+			if g.line(p.Lbrace) != g.line(child.Pos()) {
+				indent++
+			}
+		}
+	}
+	return indent
+}
+
+func (g *textEditor) line(pos token.Pos) int {
+	return g.fset.Position(pos).Line
+}
+
+func caseBody(x ast.Node) []ast.Stmt {
+	switch x := x.(type) {
+	case *ast.CaseClause:
+		return x.Body
+	case *ast.CommClause:
+		return x.Body
+	default:
+		return nil
+	}
+}
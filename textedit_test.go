@@ -0,0 +1,75 @@
+package astcopy_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"testing"
+
+	"github.com/go-toolsmith/strparse"
+	"github.com/vvakame/astcopy"
+)
+
+func TestTextEdits(t *testing.T) {
+	const src = `package p
+
+func f(x int) int {
+	// Double x.
+	y := x * 2
+	if y > 10 {
+		return y
+	}
+	return x + 1
+}
+`
+	const want = `package p
+
+func f(x int) int {
+	// Double x.
+	y := x * 3
+	if y > 10 {
+		log(y)
+		return y
+	}
+	return x - 1
+}
+`
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nMap := astcopy.CopyNodeMap{}
+	cp := astcopy.File(f, nMap)
+
+	body := cp.Decls[0].(*ast.FuncDecl).Body
+	body.List[0].(*ast.AssignStmt).Rhs[0].(*ast.BinaryExpr).Y = &ast.BasicLit{Kind: token.INT, Value: "3"}
+	ifBody := body.List[1].(*ast.IfStmt).Body
+	ifBody.List = append([]ast.Stmt{strparse.Stmt(`log(y)`)}, ifBody.List...)
+	body.List[2].(*ast.ReturnStmt).Results[0].(*ast.BinaryExpr).Op = token.SUB
+
+	edits, err := astcopy.TextEdits(fset, f, cp, nMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits) != 3 {
+		t.Errorf("have %d edits, want 3", len(edits))
+	}
+	if have := applyEdits(fset, src, edits); have != want {
+		t.Errorf("result mismatch:\nhave:\n%s\nwant:\n%s", have, want)
+	}
+}
+
+func applyEdits(fset *token.FileSet, src string, edits []astcopy.TextEdit) string {
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].Pos > edits[j].Pos
+	})
+	for _, e := range edits {
+		start := fset.Position(e.Pos).Offset
+		end := fset.Position(e.End).Offset
+		src = src[:start] + string(e.NewText) + src[end:]
+	}
+	return src
+}
@@ -0,0 +1,459 @@
+package astcopy
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// ValidationError describes a malformed node.
+type ValidationError struct {
+	// Node is the malformed node, it is nil for missing list elements.
+	Node ast.Node
+	// Path is the node reference path from the validated root,
+	// like "File.Decls[0].Body.List[1]".
+	Path string
+	// Problem describes the node problem, like "missing Type".
+	Problem string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("astcopy: invalid node at %s: %s", e.Path, e.Problem)
+}
+
+// Validate checks that required fields of the nodes reachable from x
+// are set and that their tokens are consistent, so the tree can be
+// printed with go/printer.
+// It returns *ValidationError for the first malformed node.
+//
+// Validate checks the tree structure only, it doesn't type check
+// and doesn't resolve identifiers.
+// Nodes referenced more than once are checked once.
+func Validate(x ast.Node) error {
+	x = orNil(x)
+	if x == nil {
+		return nil
+	}
+
+	seen := make(map[ast.Node]bool)
+	type task struct {
+		x    ast.Node
+		path *copyPath
+	}
+	stack := []task{{x: x, path: &copyPath{x: x, index: -1}}}
+	for len(stack) != 0 {
+		t := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[t.x] {
+			continue
+		}
+		seen[t.x] = true
+
+		if problem := checkNode(t.x); problem != "" {
+			return &ValidationError{Node: t.x, Path: t.path.String(), Problem: problem}
+		}
+
+		n := len(stack)
+		for _, f := range nodeFields(t.x) {
+			switch {
+			case isNodeField(f.ptr):
+				if child := getNode(f.ptr); child != nil {
+					stack = append(stack, task{x: child, path: &copyPath{parent: t.path, x: child, field: f.name, index: -1}})
+				}
+			case isListField(f.ptr):
+				for i, child := range getList(f.ptr) {
+					path := &copyPath{parent: t.path, x: child, field: f.name, index: i}
+					if child == nil {
+						return &ValidationError{Path: path.String(), Problem: "nil list element"}
+					}
+					stack = append(stack, task{x: child, path: path})
+				}
+			}
+		}
+		for i, j := n, len(stack)-1; i < j; i, j = i+1, j-1 {
+			stack[i], stack[j] = stack[j], stack[i]
+		}
+
+		if pkg, ok := t.x.(*ast.Package); ok {
+			names := sortedFileNames(pkg)
+			for i := len(names) - 1; i >= 0; i-- {
+				f := pkg.Files[names[i]]
+				path := &copyPath{parent: t.path, x: f, field: "Files[" + strconv.Quote(names[i]) + "]", index: -1}
+				if f == nil {
+					return &ValidationError{Path: path.String(), Problem: "nil file"}
+				}
+				stack = append(stack, task{x: f, path: path})
+			}
+		}
+	}
+	return nil
+}
+
+// checkNode returns x problem description or an empty string
+// if x fields are valid. Children are not checked.
+func checkNode(x ast.Node) string {
+	missing := func(name string) string {
+		return "missing " + name
+	}
+	invalidTok := func(name string, tok token.Token) string {
+		return fmt.Sprintf("invalid %s %s", name, tokenCode(tok))
+	}
+
+	switch x := x.(type) {
+	case *ast.Ident:
+		if x.Name == "" {
+			return missing("Name")
+		}
+	case *ast.BasicLit:
+		switch x.Kind {
+		case token.INT, token.FLOAT, token.IMAG, token.CHAR, token.STRING:
+		default:
+			return invalidTok("Kind", x.Kind)
+		}
+		if x.Value == "" {
+			return missing("Value")
+		}
+	case *ast.FuncLit:
+		if x.Type == nil {
+			return missing("Type")
+		}
+		if x.Body == nil {
+			return missing("Body")
+		}
+	case *ast.ParenExpr:
+		if orNil(x.X) == nil {
+			return missing("X")
+		}
+	case *ast.SelectorExpr:
+		if orNil(x.X) == nil {
+			return missing("X")
+		}
+		if x.Sel == nil {
+			return missing("Sel")
+		}
+	case *ast.IndexExpr:
+		if orNil(x.X) == nil {
+			return missing("X")
+		}
+		if orNil(x.Index) == nil {
+			return missing("Index")
+		}
+	case *ast.IndexListExpr:
+		if orNil(x.X) == nil {
+			return missing("X")
+		}
+		if len(x.Indices) == 0 {
+			return missing("Indices")
+		}
+	case *ast.SliceExpr:
+		if orNil(x.X) == nil {
+			return missing("X")
+		}
+		if x.Slice3 && orNil(x.High) == nil {
+			return missing("High")
+		}
+		if x.Slice3 && orNil(x.Max) == nil {
+			return missing("Max")
+		}
+		if !x.Slice3 && orNil(x.Max) != nil {
+			return "Max without Slice3"
+		}
+	case *ast.TypeAssertExpr:
+		if orNil(x.X) == nil {
+			return missing("X")
+		}
+	case *ast.CallExpr:
+		if orNil(x.Fun) == nil {
+			return missing("Fun")
+		}
+	case *ast.StarExpr:
+		if orNil(x.X) == nil {
+			return missing("X")
+		}
+	case *ast.UnaryExpr:
+		switch x.Op {
+		case token.ADD, token.SUB, token.NOT, token.XOR, token.MUL, token.AND, token.ARROW, token.TILDE:
+		default:
+			return invalidTok("Op", x.Op)
+		}
+		if orNil(x.X) == nil {
+			return missing("X")
+		}
+	case *ast.BinaryExpr:
+		if x.Op.Precedence() == token.LowestPrec {
+			return invalidTok("Op", x.Op)
+		}
+		if orNil(x.X) == nil {
+			return missing("X")
+		}
+		if orNil(x.Y) == nil {
+			return missing("Y")
+		}
+	case *ast.KeyValueExpr:
+		if orNil(x.Key) == nil {
+			return missing("Key")
+		}
+		if orNil(x.Value) == nil {
+			return missing("Value")
+		}
+	case *ast.ArrayType:
+		if orNil(x.Elt) == nil {
+			return missing("Elt")
+		}
+	case *ast.StructType:
+		if x.Fields == nil {
+			return missing("Fields")
+		}
+	case *ast.Field:
+		if orNil(x.Type) == nil {
+			return missing("Type")
+		}
+	case *ast.FuncType:
+		if x.Params == nil {
+			return missing("Params")
+		}
+	case *ast.InterfaceType:
+		if x.Methods == nil {
+			return missing("Methods")
+		}
+	case *ast.MapType:
+		if orNil(x.Key) == nil {
+			return missing("Key")
+		}
+		if orNil(x.Value) == nil {
+			return missing("Value")
+		}
+	case *ast.ChanType:
+		switch x.Dir {
+		case ast.SEND, ast.RECV, ast.SEND | ast.RECV:
+		default:
+			return "invalid Dir " + chanDirCode(x.Dir)
+		}
+		if orNil(x.Value) == nil {
+			return missing("Value")
+		}
+
+	case *ast.DeclStmt:
+		decl, ok := x.Decl.(*ast.GenDecl)
+		if !ok {
+			return "Decl is not a GenDecl"
+		}
+		if decl.Tok == token.IMPORT {
+			return "import declaration statement"
+		}
+	case *ast.LabeledStmt:
+		if x.Label == nil {
+			return missing("Label")
+		}
+		if orNil(x.Stmt) == nil {
+			return missing("Stmt")
+		}
+	case *ast.ExprStmt:
+		if orNil(x.X) == nil {
+			return missing("X")
+		}
+	case *ast.SendStmt:
+		if orNil(x.Chan) == nil {
+			return missing("Chan")
+		}
+		if orNil(x.Value) == nil {
+			return missing("Value")
+		}
+	case *ast.IncDecStmt:
+		if x.Tok != token.INC && x.Tok != token.DEC {
+			return invalidTok("Tok", x.Tok)
+		}
+		if orNil(x.X) == nil {
+			return missing("X")
+		}
+	case *ast.AssignStmt:
+		switch x.Tok {
+		case token.ASSIGN, token.DEFINE:
+		case token.ADD_ASSIGN, token.SUB_ASSIGN, token.MUL_ASSIGN, token.QUO_ASSIGN, token.REM_ASSIGN,
+			token.AND_ASSIGN, token.OR_ASSIGN, token.XOR_ASSIGN, token.SHL_ASSIGN, token.SHR_ASSIGN, token.AND_NOT_ASSIGN:
+			if len(x.Lhs) > 1 || len(x.Rhs) > 1 {
+				return fmt.Sprintf("%s with multiple operands", tokenCode(x.Tok))
+			}
+		default:
+			return invalidTok("Tok", x.Tok)
+		}
+		if len(x.Lhs) == 0 {
+			return missing("Lhs")
+		}
+		if len(x.Rhs) == 0 {
+			return missing("Rhs")
+		}
+	case *ast.GoStmt:
+		if x.Call == nil {
+			return missing("Call")
+		}
+	case *ast.DeferStmt:
+		if x.Call == nil {
+			return missing("Call")
+		}
+	case *ast.BranchStmt:
+		switch x.Tok {
+		case token.BREAK, token.CONTINUE:
+		case token.GOTO:
+			if x.Label == nil {
+				return missing("Label")
+			}
+		case token.FALLTHROUGH:
+			if x.Label != nil {
+				return "FALLTHROUGH with Label"
+			}
+		default:
+			return invalidTok("Tok", x.Tok)
+		}
+	case *ast.IfStmt:
+		if orNil(x.Cond) == nil {
+			return missing("Cond")
+		}
+		if x.Body == nil {
+			return missing("Body")
+		}
+		switch x.Else.(type) {
+		case nil, *ast.IfStmt, *ast.BlockStmt:
+		default:
+			return "Else is not an IfStmt or a BlockStmt"
+		}
+	case *ast.SwitchStmt:
+		if x.Body == nil {
+			return missing("Body")
+		}
+		for _, stmt := range x.Body.List {
+			if _, ok := stmt.(*ast.CaseClause); !ok {
+				return "Body statement is not a CaseClause"
+			}
+		}
+	case *ast.TypeSwitchStmt:
+		switch assign := x.Assign.(type) {
+		case *ast.ExprStmt:
+			if !isTypeSwitchGuard(assign.X) {
+				return "Assign is not a type switch guard"
+			}
+		case *ast.AssignStmt:
+			if assign.Tok != token.DEFINE || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 || !isTypeSwitchGuard(assign.Rhs[0]) {
+				return "Assign is not a type switch guard"
+			}
+		case nil:
+			return missing("Assign")
+		default:
+			return "Assign is not a type switch guard"
+		}
+		if x.Body == nil {
+			return missing("Body")
+		}
+		for _, stmt := range x.Body.List {
+			if _, ok := stmt.(*ast.CaseClause); !ok {
+				return "Body statement is not a CaseClause"
+			}
+		}
+	case *ast.CommClause:
+		switch x.Comm.(type) {
+		case nil, *ast.SendStmt, *ast.ExprStmt, *ast.AssignStmt:
+		default:
+			return "Comm is not a send or receive statement"
+		}
+	case *ast.SelectStmt:
+		if x.Body == nil {
+			return missing("Body")
+		}
+		for _, stmt := range x.Body.List {
+			if _, ok := stmt.(*ast.CommClause); !ok {
+				return "Body statement is not a CommClause"
+			}
+		}
+	case *ast.ForStmt:
+		if x.Body == nil {
+			return missing("Body")
+		}
+	case *ast.RangeStmt:
+		switch {
+		case orNil(x.Key) == nil && orNil(x.Value) != nil:
+			return missing("Key")
+		case orNil(x.Key) == nil && x.Tok != token.ILLEGAL:
+			return invalidTok("Tok", x.Tok)
+		case orNil(x.Key) != nil && x.Tok != token.ASSIGN && x.Tok != token.DEFINE:
+			return invalidTok("Tok", x.Tok)
+		}
+		if orNil(x.X) == nil {
+			return missing("X")
+		}
+		if x.Body == nil {
+			return missing("Body")
+		}
+
+	case *ast.ImportSpec:
+		if x.Path == nil {
+			return missing("Path")
+		}
+		if x.Path.Kind != token.STRING {
+			return invalidTok("Path.Kind", x.Path.Kind)
+		}
+	case *ast.ValueSpec:
+		if len(x.Names) == 0 {
+			return missing("Names")
+		}
+	case *ast.TypeSpec:
+		if x.Name == nil {
+			return missing("Name")
+		}
+		if orNil(x.Type) == nil {
+			return missing("Type")
+		}
+
+	case *ast.GenDecl:
+		switch x.Tok {
+		case token.IMPORT, token.CONST, token.VAR, token.TYPE:
+		default:
+			return invalidTok("Tok", x.Tok)
+		}
+		for _, spec := range x.Specs {
+			var ok bool
+			switch x.Tok {
+			case token.IMPORT:
+				_, ok = spec.(*ast.ImportSpec)
+			case token.CONST, token.VAR:
+				_, ok = spec.(*ast.ValueSpec)
+			case token.TYPE:
+				_, ok = spec.(*ast.TypeSpec)
+			}
+			if !ok && spec != nil {
+				return fmt.Sprintf("%s spec in %s declaration", nodeName(spec), tokenCode(x.Tok))
+			}
+		}
+	case *ast.FuncDecl:
+		if x.Recv != nil && len(x.Recv.List) != 1 {
+			return "Recv must have one field"
+		}
+		if x.Name == nil {
+			return missing("Name")
+		}
+		if x.Type == nil {
+			return missing("Type")
+		}
+
+	case *ast.Comment:
+		if !strings.HasPrefix(x.Text, "//") && !strings.HasPrefix(x.Text, "/*") {
+			return "Text is not a comment"
+		}
+	case *ast.CommentGroup:
+		if len(x.List) == 0 {
+			return missing("List")
+		}
+	case *ast.File:
+		if x.Name == nil {
+			return missing("Name")
+		}
+	}
+	return ""
+}
+
+// isTypeSwitchGuard reports whether x is an x.(type) expression.
+func isTypeSwitchGuard(x ast.Expr) bool {
+	assert, ok := x.(*ast.TypeAssertExpr)
+	return ok && orNil(assert.Type) == nil
+}
@@ -0,0 +1,143 @@
+package astcopy_test
+
+import (
+	"context"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/vvakame/astcopy"
+)
+
+func TestValidateGoroot(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(runtime.GOROOT(), "src", "go", "*", "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	pkg := &ast.Package{Name: "std", Files: map[string]*ast.File{}}
+	for _, name := range files {
+		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pkg.Files[name] = f
+	}
+	if err := astcopy.Validate(pkg); err != nil {
+		t.Fatal(err)
+	}
+	c := astcopy.Copier{Validate: true}
+	cp, err := c.CopyContext(context.Background(), pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := astcopy.Validate(cp); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	const src = `package p
+
+func f(a, b int) int {
+	a += b
+	if a > b {
+		return a
+	}
+	return b
+}
+`
+
+	tests := []struct {
+		name    string
+		modify  func(f *ast.File)
+		path    string
+		problem string
+	}{
+		{
+			name:    "func type",
+			modify:  func(f *ast.File) { f.Decls[0].(*ast.FuncDecl).Type = nil },
+			path:    "File.Decls[0]",
+			problem: "missing Type",
+		},
+		{
+			name: "op assign operands",
+			modify: func(f *ast.File) {
+				assign := f.Decls[0].(*ast.FuncDecl).Body.List[0].(*ast.AssignStmt)
+				assign.Lhs = append(assign.Lhs, ast.NewIdent("b"))
+				assign.Rhs = append(assign.Rhs, ast.NewIdent("a"))
+			},
+			path:    "File.Decls[0].Body.List[0]",
+			problem: "token.ADD_ASSIGN with multiple operands",
+		},
+		{
+			name: "binary op",
+			modify: func(f *ast.File) {
+				f.Decls[0].(*ast.FuncDecl).Body.List[1].(*ast.IfStmt).Cond.(*ast.BinaryExpr).Op = token.ASSIGN
+			},
+			path:    "File.Decls[0].Body.List[1].Cond",
+			problem: "invalid Op token.ASSIGN",
+		},
+		{
+			name: "call fun",
+			modify: func(f *ast.File) {
+				ret := f.Decls[0].(*ast.FuncDecl).Body.List[2].(*ast.ReturnStmt)
+				ret.Results[0] = &ast.CallExpr{}
+			},
+			path:    "File.Decls[0].Body.List[2].Results[0]",
+			problem: "missing Fun",
+		},
+		{
+			name: "typed nil call fun",
+			modify: func(f *ast.File) {
+				ret := f.Decls[0].(*ast.FuncDecl).Body.List[2].(*ast.ReturnStmt)
+				ret.Results[0] = &ast.CallExpr{Fun: (*ast.Ident)(nil)}
+			},
+			path:    "File.Decls[0].Body.List[2].Results[0]",
+			problem: "missing Fun",
+		},
+		{
+			name: "nil statement",
+			modify: func(f *ast.File) {
+				f.Decls[0].(*ast.FuncDecl).Body.List[1].(*ast.IfStmt).Body.List[0] = nil
+			},
+			path:    "File.Decls[0].Body.List[1].Body.List[0]",
+			problem: "nil list element",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := astcopy.Validate(f); err != nil {
+				t.Fatalf("before modification: %v", err)
+			}
+			tt.modify(f)
+
+			check := func(err error) {
+				t.Helper()
+				var verr *astcopy.ValidationError
+				if !errors.As(err, &verr) {
+					t.Fatalf("have %v, want *ValidationError", err)
+				}
+				if verr.Path != tt.path || verr.Problem != tt.problem {
+					t.Errorf("have %q: %q, want %q: %q", verr.Path, verr.Problem, tt.path, tt.problem)
+				}
+			}
+			check(astcopy.Validate(f))
+
+			c := astcopy.Copier{Validate: true}
+			cp, err := c.CopyContext(context.Background(), f)
+			if cp != nil {
+				t.Errorf("have copy %T, want nil", cp)
+			}
+			check(err)
+		})
+	}
+}
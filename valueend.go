@@ -0,0 +1,15 @@
+//go:build !go1.26
+
+package astcopy
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// valueEnd returns a pointer to the ValueEnd field of x.
+// ast.BasicLit has no ValueEnd before go1.26, so the returned position
+// is always token.NoPos and writing it has no effect.
+func valueEnd(x *ast.BasicLit) *token.Pos {
+	return new(token.Pos)
+}
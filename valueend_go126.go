@@ -0,0 +1,13 @@
+//go:build go1.26
+
+package astcopy
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// valueEnd returns a pointer to the ValueEnd field of x.
+func valueEnd(x *ast.BasicLit) *token.Pos {
+	return &x.ValueEnd
+}